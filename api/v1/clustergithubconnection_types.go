@@ -0,0 +1,82 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ClusterGithubConnectionSpec defines the desired state of ClusterGithubConnection
+type ClusterGithubConnectionSpec struct {
+	// Host is the GitHub host, github.com or the host of a GitHub Enterprise Server.
+	// +kubebuilder:default=github.com
+	// +optional
+	Host string `json:"host,omitempty"`
+	// SecretRef names the Secret that holds the credentials, with the same
+	// keys as the Secret of a GithubConnection. Its namespace is required.
+	SecretRef corev1.SecretReference `json:"secretRef"`
+	// NamespaceSelector selects the namespaces whose GithubIssuers may use the
+	// connection. When omitted no namespace may use it, an empty selector
+	// allows every namespace.
+	// +optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+	// HTTP configures how GitHub is reached with this connection.
+	// +optional
+	HTTP *HTTPSettings `json:"http,omitempty"`
+	// API is the GitHub API issues are read and written with. GraphQL keeps
+	// the issues of a repo current with one query for all of them.
+	// +kubebuilder:default=REST
+	// +optional
+	API ConnectionAPI `json:"api,omitempty"`
+}
+
+// ClusterGithubConnectionStatus defines the observed state of ClusterGithubConnection
+type ClusterGithubConnectionStatus struct {
+	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
+	// Important: Run "make" to regenerate code after modifying this file
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:scope=Cluster
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Host",type=string,JSONPath=`.spec.host`
+//+kubebuilder:printcolumn:name="Secret",type=string,JSONPath=`.spec.secretRef.name`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// ClusterGithubConnection is the Schema for the clustergithubconnections API.
+// It shares one GitHub credential with the GithubIssuers of selected namespaces.
+type ClusterGithubConnection struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ClusterGithubConnectionSpec   `json:"spec,omitempty"`
+	Status ClusterGithubConnectionStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ClusterGithubConnectionList contains a list of ClusterGithubConnection
+type ClusterGithubConnectionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterGithubConnection `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ClusterGithubConnection{}, &ClusterGithubConnectionList{})
+}
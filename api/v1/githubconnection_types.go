@@ -0,0 +1,126 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Keys read from the Secret of a GithubConnection. A Secret holds either
+// tokens or the credentials of a GitHub App.
+const (
+	// ConnectionSecretToken holds a personal access token, or several separated
+	// by commas or newlines that requests are spread across.
+	ConnectionSecretToken = "token"
+	// ConnectionSecretAppID holds the ID of a GitHub App.
+	ConnectionSecretAppID = "app-id"
+	// ConnectionSecretPrivateKey holds the PEM encoded private key of the GitHub App.
+	ConnectionSecretPrivateKey = "private-key"
+	// ConnectionSecretInstallationID optionally pins the installation of the
+	// GitHub App. Several installations separated by commas are pooled.
+	ConnectionSecretInstallationID = "installation-id"
+)
+
+// ConnectionAPI is the GitHub API issues are read and written with.
+// +kubebuilder:validation:Enum=REST;GraphQL
+type ConnectionAPI string
+
+const (
+	ConnectionAPIREST    ConnectionAPI = "REST"
+	ConnectionAPIGraphQL ConnectionAPI = "GraphQL"
+)
+
+// HTTPSettings configure how the controller reaches GitHub. Unset fields
+// fall back to the settings of the controller.
+type HTTPSettings struct {
+	// ProxyURL is the HTTPS proxy to reach GitHub through.
+	// +optional
+	ProxyURL string `json:"proxyURL,omitempty"`
+	// CABundle holds PEM encoded CA certificates that are trusted on top of
+	// the system ones, e.g. the CA of a proxy that re-signs TLS.
+	// +optional
+	CABundle string `json:"caBundle,omitempty"`
+	// Timeout limits every request to GitHub.
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+	// MaxIdleConns limits the idle connections kept open.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxIdleConns int `json:"maxIdleConns,omitempty"`
+	// MaxIdleConnsPerHost limits the idle connections kept open to each host.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxIdleConnsPerHost int `json:"maxIdleConnsPerHost,omitempty"`
+	// MaxConnsPerHost limits the connections to each host.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxConnsPerHost int `json:"maxConnsPerHost,omitempty"`
+}
+
+// GithubConnectionSpec defines the desired state of GithubConnection
+type GithubConnectionSpec struct {
+	// Host is the GitHub host, github.com or the host of a GitHub Enterprise Server.
+	// +kubebuilder:default=github.com
+	// +optional
+	Host string `json:"host,omitempty"`
+	// SecretRef names the Secret in the same namespace that holds the credentials.
+	SecretRef corev1.LocalObjectReference `json:"secretRef"`
+	// HTTP configures how GitHub is reached with this connection.
+	// +optional
+	HTTP *HTTPSettings `json:"http,omitempty"`
+	// API is the GitHub API issues are read and written with. GraphQL keeps
+	// the issues of a repo current with one query for all of them.
+	// +kubebuilder:default=REST
+	// +optional
+	API ConnectionAPI `json:"api,omitempty"`
+}
+
+// GithubConnectionStatus defines the observed state of GithubConnection
+type GithubConnectionStatus struct {
+	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
+	// Important: Run "make" to regenerate code after modifying this file
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Host",type=string,JSONPath=`.spec.host`
+//+kubebuilder:printcolumn:name="Secret",type=string,JSONPath=`.spec.secretRef.name`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// GithubConnection is the Schema for the githubconnections API. It holds the
+// GitHub credentials GithubIssuers of its namespace can refer to.
+type GithubConnection struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   GithubConnectionSpec   `json:"spec,omitempty"`
+	Status GithubConnectionStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// GithubConnectionList contains a list of GithubConnection
+type GithubConnectionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []GithubConnection `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&GithubConnection{}, &GithubConnectionList{})
+}
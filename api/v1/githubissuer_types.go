@@ -23,15 +23,149 @@ import (
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
 // NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
 
+// MissingLabelPolicy decides what happens to spec labels that don't exist in the repo.
+// +kubebuilder:validation:Enum=Create;Reject
+type MissingLabelPolicy string
+
+const (
+	// MissingLabelPolicyCreate creates missing labels in the repo.
+	MissingLabelPolicyCreate MissingLabelPolicy = "Create"
+	// MissingLabelPolicyReject leaves missing labels off the issue and reports them in the status.
+	MissingLabelPolicyReject MissingLabelPolicy = "Reject"
+)
+
+// IssueState is the state of a GitHub issue.
+// +kubebuilder:validation:Enum=open;closed
+type IssueState string
+
+const (
+	IssueStateOpen   IssueState = "open"
+	IssueStateClosed IssueState = "closed"
+)
+
+// IssueStateReason tells GitHub why an issue was closed.
+// +kubebuilder:validation:Enum=completed;not_planned
+type IssueStateReason string
+
+const (
+	IssueStateReasonCompleted  IssueStateReason = "completed"
+	IssueStateReasonNotPlanned IssueStateReason = "not_planned"
+)
+
+// DeletionPolicy decides what happens to the issue when the GithubIssuer is deleted.
+// +kubebuilder:validation:Enum=Close;Orphan
+type DeletionPolicy string
+
+const (
+	// DeletionPolicyClose closes the issue.
+	DeletionPolicyClose DeletionPolicy = "Close"
+	// DeletionPolicyOrphan leaves the issue on GitHub as it is.
+	DeletionPolicyOrphan DeletionPolicy = "Orphan"
+)
+
+// ConnectionKind is the kind of connection a GithubIssuer refers to.
+// +kubebuilder:validation:Enum=GithubConnection;ClusterGithubConnection
+type ConnectionKind string
+
+const (
+	// ConnectionKindNamespaced is a GithubConnection in the namespace of the GithubIssuer.
+	ConnectionKindNamespaced ConnectionKind = "GithubConnection"
+	// ConnectionKindCluster is a ClusterGithubConnection.
+	ConnectionKindCluster ConnectionKind = "ClusterGithubConnection"
+)
+
+// ConnectionReference points at the connection whose credentials are used.
+type ConnectionReference struct {
+	// Kind is either GithubConnection or ClusterGithubConnection.
+	// +kubebuilder:default=GithubConnection
+	// +optional
+	Kind ConnectionKind `json:"kind,omitempty"`
+	// Name of the GithubConnection in the namespace of the GithubIssuer, or
+	// of the ClusterGithubConnection.
+	Name string `json:"name"`
+}
+
 // GithubIssuerSpec defines the desired state of GithubIssuer
 type GithubIssuerSpec struct {
 	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
 
-	// +kubebuilder:validation:Pattern="^https://github.com/.*/.*$"
+	// Repo is the URL of the repo, either on github.com or on a GitHub
+	// Enterprise Server. Repos on a host the controller has no credentials
+	// for need a connection.
+	// +kubebuilder:validation:Pattern="^https://[^/]+/[^/]+/[^/]+$"
 	Repo        string `json:"repo,omitempty"`
 	Title       string `json:"title,omitempty"`
 	Description string `json:"description,omitempty"`
+
+	// Labels are the labels the issue should carry. When omitted the issue's
+	// labels are left alone, an empty list removes all of them.
+	// +optional
+	Labels []string `json:"labels"`
+	// MissingLabelPolicy decides whether labels missing from the repo are created or rejected.
+	// +kubebuilder:default=Reject
+	// +optional
+	MissingLabelPolicy MissingLabelPolicy `json:"missingLabelPolicy,omitempty"`
+	// Assignees are the GitHub logins the issue should be assigned to. When
+	// omitted the issue's assignees are left alone, an empty list unassigns everybody.
+	// +optional
+	Assignees []string `json:"assignees"`
+	// Milestone is the title of the repo milestone the issue belongs to.
+	// +optional
+	Milestone string `json:"milestone,omitempty"`
+	// State is the wanted state of the issue. When omitted the issue's state is left alone.
+	// +optional
+	State IssueState `json:"state,omitempty"`
+	// StateReason is the reason given to GitHub when the controller closes the issue.
+	// +optional
+	StateReason IssueStateReason `json:"stateReason,omitempty"`
+	// DeletionPolicy decides whether the issue is closed or left alone when the GithubIssuer is deleted.
+	// +kubebuilder:default=Close
+	// +optional
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+	// ConnectionRef names the GithubConnection or ClusterGithubConnection to
+	// talk to GitHub with. When omitted the controller's own credentials are used.
+	// +optional
+	ConnectionRef *ConnectionReference `json:"connectionRef,omitempty"`
+	// ResyncInterval is how often the issue is checked against the spec when
+	// nothing changed, give or take some jitter. When omitted the controller's
+	// default is used.
+	// +optional
+	ResyncInterval *metav1.Duration `json:"resyncInterval,omitempty"`
+}
+
+// WriteOperation is what a write to GitHub does to the issue.
+// +kubebuilder:validation:Enum=Create;Update;Close
+type WriteOperation string
+
+const (
+	// WriteCreate opens the issue.
+	WriteCreate WriteOperation = "Create"
+	// WriteUpdate brings the issue in line with the spec.
+	WriteUpdate WriteOperation = "Update"
+	// WriteClose closes the issue of a deleted GithubIssuer.
+	WriteClose WriteOperation = "Close"
+)
+
+// PendingWrite is a write to GitHub that was started but isn't known to have
+// gone through yet. It's recorded before the write is sent, so that a write
+// whose outcome was lost, to a restart or a failed request, is checked for
+// and replayed instead of being made twice or forgotten. The key of a create
+// is sent along in the ownership marker of the issue, which is looked for
+// before the issue is created again. Updates and closes bring the issue to
+// the wanted state, replaying them changes nothing once they went through.
+type PendingWrite struct {
+	// Operation is what the write does to the issue.
+	Operation WriteOperation `json:"operation"`
+	// Key identifies the write. Replays of the write keep the key of the first attempt.
+	Key string `json:"key"`
+	// IssueNumber is the number of the issue written to, unset for a create.
+	// +optional
+	IssueNumber int `json:"issueNumber,omitempty"`
+	// Generation is the generation of the spec the write syncs.
+	Generation int64 `json:"generation"`
+	// StartedAt is when the write was last attempted.
+	StartedAt metav1.Time `json:"startedAt"`
 }
 
 // GithubIssuerStatus defines the observed state of GithubIssuer
@@ -39,10 +173,37 @@ type GithubIssuerStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
 	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// RejectedLabels are the spec labels that couldn't be put on the issue.
+	RejectedLabels []string `json:"rejectedLabels,omitempty"`
+	// UnassignableAssignees are the spec assignees that can't be assigned in the repo.
+	UnassignableAssignees []string `json:"unassignableAssignees,omitempty"`
+	// IssueNumber is the number of the issue on GitHub.
+	IssueNumber int `json:"issueNumber,omitempty"`
+	// IssueURL is the HTML URL of the issue.
+	IssueURL string `json:"issueURL,omitempty"`
+	// IssueNodeID is the GraphQL node ID of the issue.
+	IssueNodeID string `json:"issueNodeID,omitempty"`
+	// IssueState is the state of the issue on GitHub.
+	IssueState string `json:"issueState,omitempty"`
+	// Milestone is the title of the milestone the issue was last put in. The
+	// issue is taken out of it once the spec no longer sets a milestone.
+	Milestone string `json:"milestone,omitempty"`
+	// ObservedGeneration is the generation of the spec that was last synced to GitHub.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// LastSyncTime is when the issue was last synced successfully.
+	LastSyncTime *metav1.Time `json:"lastSyncTime,omitempty"`
+	// PendingWrite is the write to GitHub that isn't confirmed yet, if any.
+	// +optional
+	PendingWrite *PendingWrite `json:"pendingWrite,omitempty"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Number",type=integer,JSONPath=`.status.issueNumber`
+//+kubebuilder:printcolumn:name="State",type=string,JSONPath=`.status.issueState`
+//+kubebuilder:printcolumn:name="URL",type=string,JSONPath=`.status.issueURL`
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // GithubIssuer is the Schema for the githubissuers API
 type GithubIssuer struct {
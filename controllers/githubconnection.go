@@ -0,0 +1,270 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-github/github"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	githubv1 "github.com/github-issuer/api/v1"
+	"github.com/github-issuer/pkg/github_utils"
+)
+
+// connectionRefField indexes GithubIssuers by the kind and name of their connection.
+const connectionRefField = ".spec.connectionRef"
+
+var (
+	// ErrConnectionInvalid is wrapped by the errors of connections that can't be used.
+	ErrConnectionInvalid = errors.New("the connection can't be used")
+	// ErrConnectionNotAllowed is returned when a ClusterGithubConnection
+	// doesn't allow the namespace of the GithubIssuer.
+	ErrConnectionNotAllowed = errors.New("the connection isn't allowed in this namespace")
+)
+
+//+kubebuilder:rbac:groups=github.benda.io,resources=githubconnections,verbs=get;list;watch
+//+kubebuilder:rbac:groups=github.benda.io,resources=clustergithubconnections,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get
+
+// connection is what it takes to build the client of a GithubConnection or a
+// ClusterGithubConnection.
+type connection struct {
+	// key identifies the connection in the client cache.
+	key     string
+	name    string
+	version string
+	host    string
+	secret  types.NamespacedName
+	options github_utils.TransportOptions
+	backend github_utils.Backend
+}
+
+// githubClient returns the client to sync the GithubIssuer with, and the
+// backend of the API to use it with. That's the client of its connection, or
+// the controller's own one for the host of its repo when it has none, which
+// uses REST. Connection clients are rebuilt whenever the connection or its
+// Secret change.
+func (r *GithubIssuerReconciler) githubClient(ctx context.Context, githubIssuer *githubv1.GithubIssuer) (*github.Client, github_utils.Backend, error) {
+	ref := githubIssuer.Spec.ConnectionRef
+	if ref == nil {
+		githubClient, err := r.GitHubClients.ClientFor(ctx, githubIssuer.Spec.Repo)
+		if errors.Is(err, github_utils.ErrHostUnknown) {
+			return nil, nil, fmt.Errorf("%w: %v, the GithubIssuer needs a connection", ErrConnectionInvalid, err)
+		}
+		return githubClient, github_utils.REST, err
+	}
+	var conn connection
+	var err error
+	if ref.Kind == githubv1.ConnectionKindCluster {
+		conn, err = r.clusterConnection(ctx, githubIssuer.Namespace, ref.Name)
+	} else {
+		conn, err = r.namespacedConnection(ctx, githubIssuer.Namespace, ref.Name)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	host, err := github_utils.RepoHost(githubIssuer.Spec.Repo)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !strings.EqualFold(host, conn.host) {
+		return nil, nil, fmt.Errorf("%w: the repo is on %s but %s is for %s", ErrConnectionInvalid, host, conn.name, conn.host)
+	}
+	var secret corev1.Secret
+	if err := r.secretReader().Get(ctx, conn.secret, &secret); err != nil {
+		if k8serrors.IsNotFound(err) {
+			return nil, nil, fmt.Errorf("%w: Secret %s of %s doesn't exist", ErrConnectionInvalid, conn.secret, conn.name)
+		}
+		return nil, nil, err
+	}
+	creds, err := secretCredentials(&secret)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: Secret %s: %v", ErrConnectionInvalid, conn.secret, err)
+	}
+	githubClient, err := r.GitHubClients.ConnectionClient(conn.key, conn.version+"/"+secret.ResourceVersion, conn.host, creds, conn.options)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrConnectionInvalid, err)
+	}
+	return githubClient, conn.backend, nil
+}
+
+// namespacedConnection reads the GithubConnection with the given name in the namespace.
+func (r *GithubIssuerReconciler) namespacedConnection(ctx context.Context, namespace string, name string) (connection, error) {
+	key := types.NamespacedName{Namespace: namespace, Name: name}
+	var githubConnection githubv1.GithubConnection
+	if err := r.Get(ctx, key, &githubConnection); err != nil {
+		if k8serrors.IsNotFound(err) {
+			r.GitHubClients.Forget(key.String())
+			return connection{}, fmt.Errorf("%w: GithubConnection %s doesn't exist", ErrConnectionInvalid, name)
+		}
+		return connection{}, err
+	}
+	return connection{
+		key:     key.String(),
+		name:    "GithubConnection " + name,
+		version: githubConnection.ResourceVersion,
+		host:    connectionHost(githubConnection.Spec.Host),
+		secret:  types.NamespacedName{Namespace: namespace, Name: githubConnection.Spec.SecretRef.Name},
+		options: transportOptions(githubConnection.Spec.HTTP),
+		backend: github_utils.BackendFor(string(githubConnection.Spec.API)),
+	}, nil
+}
+
+// clusterConnection reads the ClusterGithubConnection with the given name and
+// makes sure it allows the namespace.
+func (r *GithubIssuerReconciler) clusterConnection(ctx context.Context, namespace string, name string) (connection, error) {
+	key := "cluster/" + name
+	var clusterConnection githubv1.ClusterGithubConnection
+	if err := r.Get(ctx, types.NamespacedName{Name: name}, &clusterConnection); err != nil {
+		if k8serrors.IsNotFound(err) {
+			r.GitHubClients.Forget(key)
+			return connection{}, fmt.Errorf("%w: ClusterGithubConnection %s doesn't exist", ErrConnectionInvalid, name)
+		}
+		return connection{}, err
+	}
+	if clusterConnection.Spec.NamespaceSelector == nil {
+		return connection{}, fmt.Errorf("%w: ClusterGithubConnection %s selects no namespaces", ErrConnectionNotAllowed, name)
+	}
+	selector, err := metav1.LabelSelectorAsSelector(clusterConnection.Spec.NamespaceSelector)
+	if err != nil {
+		return connection{}, fmt.Errorf("%w: ClusterGithubConnection %s: %v", ErrConnectionInvalid, name, err)
+	}
+	var ns corev1.Namespace
+	if err := r.Get(ctx, types.NamespacedName{Name: namespace}, &ns); err != nil {
+		return connection{}, err
+	}
+	if !selector.Matches(labels.Set(ns.Labels)) {
+		return connection{}, fmt.Errorf("%w: ClusterGithubConnection %s doesn't select namespace %s", ErrConnectionNotAllowed, name, namespace)
+	}
+	secretRef := clusterConnection.Spec.SecretRef
+	if secretRef.Namespace == "" {
+		return connection{}, fmt.Errorf("%w: the Secret of ClusterGithubConnection %s has no namespace", ErrConnectionInvalid, name)
+	}
+	return connection{
+		key:     key,
+		name:    "ClusterGithubConnection " + name,
+		version: clusterConnection.ResourceVersion,
+		host:    connectionHost(clusterConnection.Spec.Host),
+		secret:  types.NamespacedName{Namespace: secretRef.Namespace, Name: secretRef.Name},
+		options: transportOptions(clusterConnection.Spec.HTTP),
+		backend: github_utils.BackendFor(string(clusterConnection.Spec.API)),
+	}, nil
+}
+
+func connectionHost(host string) string {
+	if host == "" {
+		return "github.com"
+	}
+	return strings.ToLower(host)
+}
+
+// transportOptions converts the HTTP settings of a connection.
+func transportOptions(settings *githubv1.HTTPSettings) github_utils.TransportOptions {
+	if settings == nil {
+		return github_utils.TransportOptions{}
+	}
+	opts := github_utils.TransportOptions{
+		ProxyURL:            settings.ProxyURL,
+		CABundle:            []byte(settings.CABundle),
+		MaxIdleConns:        settings.MaxIdleConns,
+		MaxIdleConnsPerHost: settings.MaxIdleConnsPerHost,
+		MaxConnsPerHost:     settings.MaxConnsPerHost,
+	}
+	if settings.Timeout != nil {
+		opts.Timeout = settings.Timeout.Duration
+	}
+	return opts
+}
+
+// secretCredentials reads the tokens or the GitHub App credentials of a
+// GithubConnection Secret. Several tokens, or installation IDs, separated by
+// commas or whitespace are pooled.
+func secretCredentials(secret *corev1.Secret) (github_utils.Credentials, error) {
+	appID, ok := secret.Data[githubv1.ConnectionSecretAppID]
+	if !ok {
+		creds := github_utils.TokenCredentials(string(secret.Data[githubv1.ConnectionSecretToken]))
+		if creds.Token == "" && len(creds.Pool) == 0 {
+			return github_utils.Credentials{}, fmt.Errorf("neither %q nor %q is set", githubv1.ConnectionSecretToken, githubv1.ConnectionSecretAppID)
+		}
+		return creds, nil
+	}
+	app := github_utils.AppCredentials{PrivateKey: secret.Data[githubv1.ConnectionSecretPrivateKey]}
+	var err error
+	if app.AppID, err = strconv.ParseInt(strings.TrimSpace(string(appID)), 10, 64); err != nil {
+		return github_utils.Credentials{}, fmt.Errorf("invalid %s: %w", githubv1.ConnectionSecretAppID, err)
+	}
+	creds, err := github_utils.AppInstallationCredentials(app, string(secret.Data[githubv1.ConnectionSecretInstallationID]))
+	if err != nil {
+		return github_utils.Credentials{}, fmt.Errorf("invalid %s: %w", githubv1.ConnectionSecretInstallationID, err)
+	}
+	return creds, nil
+}
+
+// issuersOfConnection maps a GithubConnection or a ClusterGithubConnection
+// to the GithubIssuers that use it.
+func (r *GithubIssuerReconciler) issuersOfConnection(obj client.Object) []reconcile.Request {
+	opts := []client.ListOption{}
+	switch obj.(type) {
+	case *githubv1.ClusterGithubConnection:
+		opts = append(opts, client.MatchingFields{connectionRefField: connectionRefValue(githubv1.ConnectionKindCluster, obj.GetName())})
+	default:
+		opts = append(opts, client.InNamespace(obj.GetNamespace()), client.MatchingFields{connectionRefField: connectionRefValue(githubv1.ConnectionKindNamespaced, obj.GetName())})
+	}
+	var githubIssuers githubv1.GithubIssuerList
+	if err := r.List(context.Background(), &githubIssuers, opts...); err != nil {
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(githubIssuers.Items))
+	for _, githubIssuer := range githubIssuers.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: githubIssuer.Namespace, Name: githubIssuer.Name}})
+	}
+	return requests
+}
+
+func connectionRefValue(kind githubv1.ConnectionKind, name string) string {
+	if kind == "" {
+		kind = githubv1.ConnectionKindNamespaced
+	}
+	return string(kind) + "/" + name
+}
+
+func indexConnectionRef(obj client.Object) []string {
+	githubIssuer := obj.(*githubv1.GithubIssuer)
+	if githubIssuer.Spec.ConnectionRef == nil {
+		return nil
+	}
+	return []string{connectionRefValue(githubIssuer.Spec.ConnectionRef.Kind, githubIssuer.Spec.ConnectionRef.Name)}
+}
+
+// secretReader returns the reader of the Secrets of connections.
+func (r *GithubIssuerReconciler) secretReader() client.Reader {
+	if r.APIReader != nil {
+		return r.APIReader
+	}
+	return r.Client
+}
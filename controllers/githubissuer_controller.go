@@ -18,19 +18,28 @@ package controllers
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"strings"
 	"time"
 
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	githubv1 "github.com/github-issuer/api/v1"
 	"github.com/github-issuer/pkg/github_utils"
 	"github.com/go-logr/logr"
 	"github.com/google/go-github/github"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
 )
@@ -38,22 +47,99 @@ import (
 // GithubIssuerReconciler reconciles a GithubIssuer object
 type GithubIssuerReconciler struct {
 	client.Client
-	Scheme       *runtime.Scheme
-	GitHubClient *github.Client
+	Scheme *runtime.Scheme
+	// APIReader reads the Secrets of connections straight from the API
+	// server, so that Secrets aren't listed and watched cluster-wide. The
+	// client is used when it's nil.
+	APIReader client.Reader
+	// GitHubClients hands out the controller's own client for the GitHub host
+	// of each repo and caches the clients of GithubConnections.
+	GitHubClients *github_utils.ClientSet
+	// ClusterID is written into the ownership marker of every issue so that
+	// clusters sharing a repo don't adopt each other's issues.
+	ClusterID string
+	// Issues serves the issues of each repo from a listing shared by all of
+	// its GithubIssuers. GitHub is asked directly when it's nil.
+	Issues *github_utils.IssueIndex
+	// MaxConcurrentReconciles is how many GithubIssuers are reconciled at
+	// once, 1 when it's 0. Reconciles of the same repo and title still run
+	// one at a time.
+	MaxConcurrentReconciles int
+	// ResyncInterval is how often a GithubIssuer is synced when nothing
+	// changed and it doesn't set its own interval. It's never resynced when
+	// it's 0.
+	ResyncInterval time.Duration
+	// Shards splits the GithubIssuers between the replicas of the controller,
+	// each reconciling those of the shards it holds. Every GithubIssuer is
+	// reconciled when it's nil.
+	Shards *Shards
+
+	access accessCache
+	locks  issueLocks
 }
 
 const FinalizerName = "github.benda.io/finalizer"
 
-func (r *GithubIssuerReconciler) updateConditions(ctx context.Context, githubIssuer *githubv1.GithubIssuer, conditionType string, reason string, msg string, status metav1.ConditionStatus) error {
-	condition := metav1.Condition{Type: conditionType, Status: status, Reason: reason, Message: msg, LastTransitionTime: metav1.Time{Time: time.Now()}}
-	githubIssuer.Status.Conditions = append(githubIssuer.Status.Conditions, condition)
-	return r.Client.Status().Update(ctx, githubIssuer)
+// resyncJitter is the largest fraction of the resync interval added to it.
+const resyncJitter = 0.1
+
+const (
+	// ReadyCondition is true when the issue on GitHub matches the current spec.
+	ReadyCondition = "Ready"
+	// SyncedCondition tells whether the last sync with GitHub succeeded.
+	SyncedCondition = "Synced"
+	// GithubReachableCondition is false while GitHub can't be reached or
+	// refuses the credentials or the rate of requests.
+	GithubReachableCondition = "GithubReachable"
+	// AssigneesAssignableCondition is false while some spec assignees can't be assigned in the repo.
+	AssigneesAssignableCondition = "AssigneesAssignable"
+	// RepositoryAccessibleCondition tells whether the repo exists, has issues
+	// enabled and lets the credentials write issues.
+	RepositoryAccessibleCondition = "RepositoryAccessible"
+)
+
+// legacyConditionTypes were appended to the status by older versions of the controller.
+var legacyConditionTypes = []string{"IssueCreated", "IssueNotCreated", "IssueUpdated", "IssueNotUpdated", "IssueNotFetched"}
+
+func setCondition(githubIssuer *githubv1.GithubIssuer, conditionType string, status metav1.ConditionStatus, reason string, msg string) {
+	meta.SetStatusCondition(&githubIssuer.Status.Conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             status,
+		Reason:             reason,
+		Message:            msg,
+		ObservedGeneration: githubIssuer.Generation,
+	})
+}
 
+// updateConditions records the outcome of a sync in the Ready, Synced and
+// GithubReachable conditions and writes the status. On failure the reason is
+// derived from syncErr.
+func (r *GithubIssuerReconciler) updateConditions(ctx context.Context, githubIssuer *githubv1.GithubIssuer, reason string, msg string, syncErr error) error {
+	for _, conditionType := range legacyConditionTypes {
+		meta.RemoveStatusCondition(&githubIssuer.Status.Conditions, conditionType)
+	}
+	if syncErr == nil {
+		setCondition(githubIssuer, GithubReachableCondition, metav1.ConditionTrue, "Reachable", "GitHub answered the last request")
+		setCondition(githubIssuer, SyncedCondition, metav1.ConditionTrue, reason, msg)
+		setCondition(githubIssuer, ReadyCondition, metav1.ConditionTrue, reason, msg)
+		return r.Client.Status().Update(ctx, githubIssuer)
+	}
+	reason = errorReason(syncErr)
+	msg = msg + ": " + syncErr.Error()
+	if githubReachable(syncErr) {
+		setCondition(githubIssuer, GithubReachableCondition, metav1.ConditionTrue, "Reachable", "GitHub answered the last request")
+	} else {
+		setCondition(githubIssuer, GithubReachableCondition, metav1.ConditionFalse, reason, syncErr.Error())
+	}
+	setCondition(githubIssuer, SyncedCondition, metav1.ConditionFalse, reason, msg)
+	setCondition(githubIssuer, ReadyCondition, metav1.ConditionFalse, reason, msg)
+	return r.Client.Status().Update(ctx, githubIssuer)
 }
 
 //+kubebuilder:rbac:groups=github.benda.io,resources=githubissuers,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=github.benda.io,resources=githubissuers/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=github.benda.io,resources=githubissuers/finalizers,verbs=update
+//+kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -75,6 +161,32 @@ func (r *GithubIssuerReconciler) Reconcile(ctx context.Context, req ctrl.Request
 		log.Error(err, "Unable to fetch GithubIssuer", "githubIssuer", req.NamespacedName.String())
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
+	done, owned := r.Shards.begin(&githubIssuer)
+	if !owned {
+		// The replica holding its shard reconciles it.
+		return ctrl.Result{}, nil
+	}
+	defer done()
+	unlock := r.locks.lock(githubIssuer.Spec.Repo, githubIssuer.Spec.Title)
+	defer unlock()
+	githubClient, backend, err := r.githubClient(ctx, &githubIssuer)
+	if err != nil && (errors.Is(err, ErrConnectionInvalid) || errors.Is(err, ErrConnectionNotAllowed)) && !githubIssuer.ObjectMeta.DeletionTimestamp.IsZero() &&
+		controllerutil.ContainsFinalizer(&githubIssuer, FinalizerName) {
+		// Nothing can be done on GitHub without a connection, blocking the deletion wouldn't help.
+		log.Info("the issue is left on GitHub since the connection can't be used", "githubIssuer", req.NamespacedName.String(), "reason", err.Error())
+		return r.removeFinalizer(ctx, log, &githubIssuer)
+	}
+	if err != nil {
+		log.Error(err, "Unable to create a GitHub client for the repo", "githubIssuer", req.NamespacedName.String(), "repo", githubIssuer.Spec.Repo)
+		if err := r.updateConditions(ctx, &githubIssuer, "", "Unable to create a GitHub client", err); err != nil {
+			log.Error(err, "Unable to update githubIssuer status", "githubIssuer", req.NamespacedName.String())
+		}
+		return ctrl.Result{}, err
+	}
+	issues := r.Issues.Using(backend)
+	if pending := githubIssuer.Status.PendingWrite; pending != nil {
+		log.Info("a write to GitHub wasn't confirmed, it's checked for and replayed", "githubIssuer", req.NamespacedName.String(), "operation", pending.Operation, "key", pending.Key)
+	}
 	if githubIssuer.ObjectMeta.DeletionTimestamp.IsZero() {
 		if !controllerutil.ContainsFinalizer(&githubIssuer, FinalizerName) {
 			if err := r.addFinalizer(ctx, log, &githubIssuer); err != nil {
@@ -83,62 +195,294 @@ func (r *GithubIssuerReconciler) Reconcile(ctx context.Context, req ctrl.Request
 		}
 	} else {
 		if controllerutil.ContainsFinalizer(&githubIssuer, FinalizerName) {
-			if res, err := r.deleteIssue(ctx, log, &githubIssuer, r.GitHubClient); err != nil {
-				return res, err
-			}
-			return ctrl.Result{}, nil
+			// A rate limited close is requeued for when the limit resets
+			// without an error, the result has to be kept.
+			return r.deleteIssue(ctx, log, &githubIssuer, issues, githubClient)
 		}
 	}
-	issue, err := github_utils.FetchIssue(githubIssuer.Spec.Repo, githubIssuer.Spec.Title, ctx, r.GitHubClient)
-	if err != nil {
-		if strings.Contains(err.Error(), "The issue wasn't found") {
-			err = github_utils.CreateIssue(githubIssuer.Spec.Repo, githubIssuer.Spec.Title, githubIssuer.Spec.Description, ctx, r.GitHubClient)
-			if err != nil {
-				err = r.updateConditions(ctx, &githubIssuer, "IssueCreated", "IssueCreated", "Issue was created", metav1.ConditionTrue)
-				if err != nil {
-					log.Error(err, "Unable to update githubIssuer status", "githubIssuer", req.NamespacedName.String(), "issue", issue)
-				}
+	if err := r.checkRepoAccess(ctx, &githubIssuer, githubClient); err != nil {
+		log.Error(err, "The repo can't be used", "githubIssuer", req.NamespacedName.String(), "repo", githubIssuer.Spec.Repo)
+		if err := r.updateConditions(ctx, &githubIssuer, "", "The repo can't be used", err); err != nil {
+			log.Error(err, "Unable to update githubIssuer status", "githubIssuer", req.NamespacedName.String())
+		}
+		return syncResult(err)
+	}
+	number := boundIssueNumber(&githubIssuer)
+	if number == 0 {
+		issue, err := r.findIssue(ctx, &githubIssuer, issues, githubClient)
+		if err != nil && !errors.Is(err, github_utils.ErrIssueNotFound) {
+			log.Error(err, "Unable to fetch the specific issue in repo", "githubIssuer", req.NamespacedName.String(), "repo", githubIssuer.Spec.Repo)
+			if err := r.updateConditions(ctx, &githubIssuer, "", "Unable to look up the issue", err); err != nil {
+				log.Error(err, "Unable to update githubIssuer status", "githubIssuer", req.NamespacedName.String())
+			}
+			return syncResult(err)
+		}
+		number = issue.GetNumber()
+		if pending := githubIssuer.Status.PendingWrite; number != 0 && pending != nil && pending.Operation == githubv1.WriteCreate {
+			if owner, _ := github_utils.ParseMarker(issue.GetBody()); owner.Write == pending.Key {
+				log.Info("the unconfirmed create went through", "githubIssuer", req.NamespacedName.String(), "key", pending.Key, "number", number)
 			} else {
-				err = r.updateConditions(ctx, &githubIssuer, "IssueNotCreated", "IssueNotCreated", "Issue was not created", metav1.ConditionFalse)
-				if err != nil {
-					log.Error(err, "Unable to update githubIssuer status", "githubIssuer", req.NamespacedName.String(), "issue", issue)
-				}
+				log.Info("the unconfirmed create didn't go through, an issue created before was found", "githubIssuer", req.NamespacedName.String(), "key", pending.Key, "number", number)
 			}
-		} else {
-			log.Error(err, "Unable to fetch the specific issue in repo", "githubIssuer", req.NamespacedName.String(), "repo", githubIssuer.Spec.Repo, "issue", issue)
-			return ctrl.Result{}, client.IgnoreNotFound(err)
 		}
-	} else {
-		if err := github_utils.UpdateIssue(githubIssuer.Spec.Repo, githubIssuer.Spec.Title, githubIssuer.Spec.Description, ctx, r.GitHubClient); err != nil {
-			err = r.updateConditions(ctx, &githubIssuer, "IssueUpdated", "IssueUpdated", "Issue was updated, issue status: "+*issue.State, metav1.ConditionTrue)
-			if err != nil {
-				log.Error(err, "Unable to update githubIssuer status", "githubIssuer", req.NamespacedName.String(), "issue", issue)
-				err = r.updateConditions(ctx, &githubIssuer, "IssueNotUpdated", "IssueNotUpdated", "Issue was not updated", metav1.ConditionTrue)
-				if err != nil {
-					log.Info(err.Error())
-				}
-				log.Error(err, "Unable to update the issue", "githubIssuer", req.NamespacedName.String(), "repo", githubIssuer.Spec.Repo, "issue", issue)
+	}
+	if number == 0 {
+		if wait := pendingCreateWait(&githubIssuer); wait > 0 {
+			log.Info("waiting for the unconfirmed create to show up before creating the issue again", "githubIssuer", req.NamespacedName.String(), "key", githubIssuer.Status.PendingWrite.Key)
+			return ctrl.Result{RequeueAfter: wait}, nil
+		}
+		if err := r.beginWrite(ctx, &githubIssuer, githubv1.WriteCreate, 0); err != nil {
+			log.Error(err, "Unable to record the pending create", "githubIssuer", req.NamespacedName.String())
+			return ctrl.Result{}, err
+		}
+		opts := r.issueOptions(&githubIssuer)
+		opts.Marker.Write = githubIssuer.Status.PendingWrite.Key
+		result, err := issues.CreateIssue(githubIssuer.Spec.Repo, githubIssuer.Spec.Title, githubIssuer.Spec.Description, opts, ctx, githubClient)
+		settleWrite(&githubIssuer, err)
+		applySyncResult(&githubIssuer, result)
+		if err != nil {
+			log.Error(err, "Unable to create the issue", "githubIssuer", req.NamespacedName.String(), "repo", githubIssuer.Spec.Repo)
+			if err := r.updateConditions(ctx, &githubIssuer, "", "Issue was not created", err); err != nil {
+				log.Error(err, "Unable to update githubIssuer status", "githubIssuer", req.NamespacedName.String())
+			}
+			return syncResult(err)
+		}
+		recordIssue(&githubIssuer, result.Issue)
+		if err := r.updateConditions(ctx, &githubIssuer, "IssueCreated", "Issue was created", nil); err != nil {
+			log.Error(err, "Unable to update githubIssuer status", "githubIssuer", req.NamespacedName.String(), "issue", result.Issue)
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: r.resyncAfter(&githubIssuer)}, nil
+	}
+	// The update is only recorded once it's known to change something, a
+	// resync that finds the issue in sync shouldn't cost a status write.
+	opts := r.issueOptions(&githubIssuer)
+	opts.BeforeUpdate = func() error {
+		if err := r.beginWrite(ctx, &githubIssuer, githubv1.WriteUpdate, number); err != nil {
+			log.Error(err, "Unable to record the pending update", "githubIssuer", req.NamespacedName.String(), "number", number)
+			return err
+		}
+		return nil
+	}
+	result, err := issues.UpdateIssue(githubIssuer.Spec.Repo, number, githubIssuer.Spec.Title, githubIssuer.Spec.Description, opts, ctx, githubClient)
+	settleWrite(&githubIssuer, err)
+	if err != nil {
+		if errors.Is(err, github_utils.ErrIssueNotFound) {
+			log.Info("the tracked issue is gone, a new one will be created", "githubIssuer", req.NamespacedName.String(), "number", number)
+			githubIssuer.Status.IssueNumber = 0
+			githubIssuer.Status.IssueURL = ""
+			githubIssuer.Status.IssueNodeID = ""
+			githubIssuer.Status.IssueState = ""
+			if err := r.Status().Update(ctx, &githubIssuer); err != nil {
+				return ctrl.Result{}, err
 			}
-			return ctrl.Result{}, client.IgnoreNotFound(err)
+			return ctrl.Result{Requeue: true}, nil
 		}
+		log.Error(err, "Unable to update the issue", "githubIssuer", req.NamespacedName.String(), "repo", githubIssuer.Spec.Repo, "number", number)
+		if err := r.updateConditions(ctx, &githubIssuer, "", "Issue was not updated", err); err != nil {
+			log.Error(err, "Unable to update githubIssuer status", "githubIssuer", req.NamespacedName.String(), "number", number)
+		}
+		return syncResult(err)
+	}
+	applySyncResult(&githubIssuer, result)
+	recordIssue(&githubIssuer, result.Issue)
+	if err := r.updateConditions(ctx, &githubIssuer, "IssueSynced", "Issue is in sync with the spec", nil); err != nil {
+		log.Error(err, "Unable to update githubIssuer status", "githubIssuer", req.NamespacedName.String(), "number", number)
+		return ctrl.Result{}, err
 	}
 
-	return ctrl.Result{}, nil
+	return ctrl.Result{RequeueAfter: r.resyncAfter(&githubIssuer)}, nil
 }
 
-func (r *GithubIssuerReconciler) deleteIssue(ctx context.Context, log logr.Logger, githubIssuer *githubv1.GithubIssuer, githubClient *github.Client) (ctrl.Result, error) {
-	repo := githubIssuer.Spec.Repo
-	issue := githubIssuer.Spec.Title
-	if err := github_utils.DeleteIssue(repo, issue, ctx, githubClient); err != nil {
-		log.Error(err, "unable to delete issue from github", "githubIssuer", githubIssuer.Name, "issue", issue)
-		return ctrl.Result{Requeue: true}, err
+// resyncAfter returns when the GithubIssuer is synced again if nothing
+// changes. The interval is stretched by up to resyncJitter so that the
+// GithubIssuers synced together spread out over time.
+func (r *GithubIssuerReconciler) resyncAfter(githubIssuer *githubv1.GithubIssuer) time.Duration {
+	interval := r.ResyncInterval
+	if githubIssuer.Spec.ResyncInterval != nil && githubIssuer.Spec.ResyncInterval.Duration > 0 {
+		interval = githubIssuer.Spec.ResyncInterval.Duration
+	}
+	if interval <= 0 {
+		return 0
 	}
+	return wait.Jitter(interval, resyncJitter)
+}
+
+// syncResult turns the error of a failed sync into the result of the
+// reconcile. Rate limited syncs are requeued for when the limit resets
+// instead of being retried with backoff right away.
+func syncResult(err error) (ctrl.Result, error) {
+	var apiErr *github_utils.APIError
+	if errors.As(err, &apiErr) && errors.Is(err, github_utils.ErrRateLimited) && !apiErr.RetryAt.IsZero() {
+		requeueAfter := time.Until(apiErr.RetryAt)
+		if requeueAfter < time.Second {
+			requeueAfter = time.Second
+		}
+		return ctrl.Result{RequeueAfter: requeueAfter}, nil
+	}
+	return ctrl.Result{}, err
+}
+
+// errorReason maps an error from github_utils to the reason of a condition.
+func errorReason(err error) string {
+	switch {
+	case errors.Is(err, github_utils.ErrRepoNotFound):
+		return "RepoNotFound"
+	case errors.Is(err, github_utils.ErrMilestoneNotFound):
+		return "MilestoneNotFound"
+	case errors.Is(err, github_utils.ErrUnauthorized):
+		return "Unauthorized"
+	case errors.Is(err, github_utils.ErrForbidden):
+		return "Forbidden"
+	case errors.Is(err, github_utils.ErrRateLimited):
+		return "RateLimited"
+	case errors.Is(err, github_utils.ErrValidationFailed):
+		return "ValidationFailed"
+	case errors.Is(err, github_utils.ErrIssuesDisabled):
+		return "IssuesDisabled"
+	case errors.Is(err, github_utils.ErrIssueWriteDenied):
+		return "IssueWriteDenied"
+	case errors.Is(err, ErrConnectionInvalid):
+		return "ConnectionInvalid"
+	case errors.Is(err, ErrConnectionNotAllowed):
+		return "ConnectionNotAllowed"
+	default:
+		return "GithubError"
+	}
+}
+
+// githubReachable reports whether GitHub answered the request that failed and
+// was willing to serve it, as opposed to network errors, server errors,
+// rejected credentials and rate limits.
+func githubReachable(err error) bool {
+	var apiErr *github_utils.APIError
+	if errors.Is(err, github_utils.ErrIssuesDisabled) || errors.Is(err, github_utils.ErrIssueWriteDenied) || errors.Is(err, github_utils.ErrMilestoneNotFound) {
+		// Found out from what GitHub answered.
+		return true
+	}
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return !errors.Is(err, github_utils.ErrUnauthorized) && !errors.Is(err, github_utils.ErrRateLimited)
+}
+
+// boundIssueNumber returns the number of the issue the GithubIssuer is bound
+// to, or 0 when it still has to adopt or create one. A binding to another repo
+// than the one in the spec doesn't count.
+func boundIssueNumber(githubIssuer *githubv1.GithubIssuer) int {
+	prefix := strings.ToLower(strings.TrimSuffix(githubIssuer.Spec.Repo, "/") + "/issues/")
+	if !strings.HasPrefix(strings.ToLower(githubIssuer.Status.IssueURL), prefix) {
+		return 0
+	}
+	return githubIssuer.Status.IssueNumber
+}
+
+// recordIssue stores where the issue lives on GitHub, the milestone it was put
+// in and when it was last synced.
+func recordIssue(githubIssuer *githubv1.GithubIssuer, issue *github.Issue) {
+	if issue != nil {
+		githubIssuer.Status.IssueNumber = issue.GetNumber()
+		githubIssuer.Status.IssueURL = issue.GetHTMLURL()
+		githubIssuer.Status.IssueNodeID = issue.GetNodeID()
+		githubIssuer.Status.IssueState = issue.GetState()
+	}
+	githubIssuer.Status.Milestone = githubIssuer.Spec.Milestone
+	githubIssuer.Status.ObservedGeneration = githubIssuer.Generation
+	now := metav1.Now()
+	githubIssuer.Status.LastSyncTime = &now
+}
+
+// issueMarker builds the ownership marker embedded in the body of the issue.
+func (r *GithubIssuerReconciler) issueMarker(githubIssuer *githubv1.GithubIssuer) github_utils.Marker {
+	return github_utils.Marker{
+		ClusterID: r.ClusterID,
+		Namespace: githubIssuer.Namespace,
+		Name:      githubIssuer.Name,
+		UID:       string(githubIssuer.UID),
+	}
+}
+
+// issueOptions builds the optional issue attributes from the GithubIssuer spec.
+func (r *GithubIssuerReconciler) issueOptions(githubIssuer *githubv1.GithubIssuer) github_utils.IssueOptions {
+	marker := r.issueMarker(githubIssuer)
+	return github_utils.IssueOptions{
+		Marker:              &marker,
+		Labels:              githubIssuer.Spec.Labels,
+		CreateMissingLabels: githubIssuer.Spec.MissingLabelPolicy == githubv1.MissingLabelPolicyCreate,
+		Assignees:           githubIssuer.Spec.Assignees,
+		Milestone:           githubIssuer.Spec.Milestone,
+		ClearMilestone:      githubIssuer.Spec.Milestone == "" && githubIssuer.Status.Milestone != "",
+		State:               string(githubIssuer.Spec.State),
+		StateReason:         string(githubIssuer.Spec.StateReason),
+	}
+}
+
+// applySyncResult records the parts of the spec GitHub didn't accept in the status.
+func applySyncResult(githubIssuer *githubv1.GithubIssuer, result github_utils.SyncResult) {
+	githubIssuer.Status.RejectedLabels = result.RejectedLabels
+	githubIssuer.Status.UnassignableAssignees = result.UnassignableAssignees
+	if len(result.UnassignableAssignees) > 0 {
+		setCondition(githubIssuer, AssigneesAssignableCondition, metav1.ConditionFalse, "AssigneeNotAssignable",
+			fmt.Sprintf("Can't assign %s in %s, they may not be collaborators", strings.Join(result.UnassignableAssignees, ", "), githubIssuer.Spec.Repo))
+	} else if githubIssuer.Spec.Assignees != nil {
+		setCondition(githubIssuer, AssigneesAssignableCondition, metav1.ConditionTrue, "AssigneesAssigned", "All assignees were assigned")
+	} else {
+		// The assignees are left alone, there's nothing to tell about them.
+		meta.RemoveStatusCondition(&githubIssuer.Status.Conditions, AssigneesAssignableCondition)
+	}
+}
+
+// findIssue looks up the issue of a GithubIssuer whose number isn't known:
+// the one carrying its marker or, for GithubIssuers synced before issues
+// were marked, the unmarked one the controller opened with the same title.
+func (r *GithubIssuerReconciler) findIssue(ctx context.Context, githubIssuer *githubv1.GithubIssuer, issues github_utils.Issues, githubClient *github.Client) (*github.Issue, error) {
+	issue, err := issues.FetchIssue(githubIssuer.Spec.Repo, r.issueMarker(githubIssuer), ctx, githubClient)
+	if !errors.Is(err, github_utils.ErrIssueNotFound) {
+		return issue, err
+	}
+	issue, err = github_utils.FetchLegacyIssue(githubIssuer.Spec.Repo, githubIssuer.Spec.Title, ctx, githubClient)
+	if err == nil {
+		ctrllog.FromContext(ctx).Info("adopting the issue opened before issues were marked", "githubIssuer", githubIssuer.Name, "number", issue.GetNumber())
+	}
+	return issue, err
+}
+
+func (r *GithubIssuerReconciler) deleteIssue(ctx context.Context, log logr.Logger, githubIssuer *githubv1.GithubIssuer, issues github_utils.Issues, githubClient *github.Client) (ctrl.Result, error) {
+	if githubIssuer.Spec.DeletionPolicy != githubv1.DeletionPolicyOrphan {
+		repo := githubIssuer.Spec.Repo
+		number := boundIssueNumber(githubIssuer)
+		if number == 0 {
+			issue, err := r.findIssue(ctx, githubIssuer, issues, githubClient)
+			if err != nil && !errors.Is(err, github_utils.ErrIssueNotFound) {
+				log.Error(err, "unable to fetch the issue from github", "githubIssuer", githubIssuer.Name, "issue", githubIssuer.Spec.Title)
+				return syncResult(err)
+			}
+			number = issue.GetNumber()
+		}
+		if number != 0 {
+			if err := r.beginWrite(ctx, githubIssuer, githubv1.WriteClose, number); err != nil {
+				log.Error(err, "unable to record the pending close", "githubIssuer", githubIssuer.Name, "number", number)
+				return ctrl.Result{}, err
+			}
+			if err := issues.DeleteIssue(repo, number, ctx, githubClient); err != nil {
+				log.Error(err, "unable to delete issue from github", "githubIssuer", githubIssuer.Name, "number", number)
+				return syncResult(err)
+			}
+		}
+	}
+	res, err := r.removeFinalizer(ctx, log, githubIssuer)
+	if err == nil {
+		log.Info("issue was deleted", "githubIssuer", githubIssuer.Name)
+	}
+	return res, err
+}
+
+func (r *GithubIssuerReconciler) removeFinalizer(ctx context.Context, log logr.Logger, githubIssuer *githubv1.GithubIssuer) (ctrl.Result, error) {
 	controllerutil.RemoveFinalizer(githubIssuer, FinalizerName)
 	if err := r.Update(ctx, githubIssuer); err != nil {
 		log.Error(err, "unable to remove finalizer from githubissuer", "githubIssuer", githubIssuer.Name)
 		return ctrl.Result{Requeue: true}, err
 	}
-	log.Info("issue was deleted", "githubIssuer", githubIssuer.Name)
 	return ctrl.Result{}, nil
 }
 
@@ -153,7 +497,21 @@ func (r *GithubIssuerReconciler) addFinalizer(ctx context.Context, log logr.Logg
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *GithubIssuerReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&githubv1.GithubIssuer{}).
-		Complete(r)
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &githubv1.GithubIssuer{}, connectionRefField, indexConnectionRef); err != nil {
+		return err
+	}
+	b := ctrl.NewControllerManagedBy(mgr).
+		// Status updates, including the controller's own, don't need a sync.
+		For(&githubv1.GithubIssuer{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
+		Watches(&source.Kind{Type: &githubv1.GithubConnection{}}, handler.EnqueueRequestsFromMapFunc(r.issuersOfConnection)).
+		Watches(&source.Kind{Type: &githubv1.ClusterGithubConnection{}}, handler.EnqueueRequestsFromMapFunc(r.issuersOfConnection))
+	if r.Shards != nil {
+		if err := mgr.Add(r.Shards); err != nil {
+			return err
+		}
+		// The GithubIssuers of a shard taken over are synced right away.
+		b = b.Watches(r.Shards.source(), &handler.EnqueueRequestForObject{})
+	}
+	return b.Complete(r)
 }
@@ -4,16 +4,26 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	githubv1 "github.com/github-issuer/api/v1"
 	"github.com/github-issuer/pkg/github_utils"
+	"github.com/go-logr/logr"
 	"github.com/google/go-github/github"
 	"github.com/migueleliasweb/go-github-mock/src/mock"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	coordinationv1 "k8s.io/api/coordination/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 )
 
 var _ = Describe("GithubIssuer controller", func() {
@@ -66,7 +76,7 @@ var _ = Describe("GithubIssuer controller", func() {
 					[]github.Issue{
 						{
 							Title:  github.String(ISSUE),
-							Body:   github.String(DESCRIPTION),
+							Body:   github.String(DESCRIPTION + "\n\n" + OWNER.String()),
 							Number: github.Int(NUMBER),
 							Repository: &github.Repository{
 								Name: github.String(REPO),
@@ -83,7 +93,7 @@ var _ = Describe("GithubIssuer controller", func() {
 				),
 			)
 			nclient := github.NewClient(mockedHTTPClient)
-			issue, err := github_utils.FetchIssue(githubIssuer.Spec.Repo, githubIssuer.Spec.Title, ctx, nclient)
+			issue, err := github_utils.FetchIssue(githubIssuer.Spec.Repo, OWNER, ctx, nclient)
 			Expect(issue != nil && err == nil).Should(BeTrue())
 
 		})
@@ -119,10 +129,49 @@ var _ = Describe("GithubIssuer controller", func() {
 				),
 			)
 			nclient := github.NewClient(mockedHTTPClient)
-			_, err = github_utils.FetchIssue(githubIssuer.Spec.Repo, githubIssuer.Spec.Title, ctx, nclient)
+			_, err = github_utils.FetchIssue(githubIssuer.Spec.Repo, OWNER, ctx, nclient)
 			Expect(err != nil).Should(BeTrue())
 
 		})
+		It("should retry a rate limited close once the limit resets", func() {
+			// A fake client keeps the GithubIssuer away from the manager's reconciler.
+			key := types.NamespacedName{Name: "rate-limited-close", Namespace: "rate-limited-close"}
+			githubIssuer := &githubv1.GithubIssuer{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:       key.Name,
+					Namespace:  key.Namespace,
+					Finalizers: []string{FinalizerName},
+				},
+				Spec: githubv1.GithubIssuerSpec{
+					Repo:        "https://github.com/test-user/test-repo",
+					Title:       "test-title",
+					Description: "test-body",
+				},
+				Status: githubv1.GithubIssuerStatus{
+					IssueNumber: 1,
+					IssueURL:    "https://github.com/test-user/test-repo/issues/1",
+				},
+			}
+			c := fake.NewClientBuilder().WithScheme(k8sClient.Scheme()).WithObjects(githubIssuer).Build()
+			Expect(c.Delete(ctx, githubIssuer)).Should(Succeed())
+			reset := time.Now().Add(time.Minute)
+			mockedHTTPClient := mock.NewMockedHTTPClient(
+				mock.WithRequestMatchHandler(
+					mock.PatchReposIssuesByOwnerByRepoByIssueNumber,
+					http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+						w.Header().Set("X-RateLimit-Remaining", "0")
+						w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+						mock.WriteError(w, http.StatusForbidden, "API rate limit exceeded")
+					}),
+				),
+			)
+			reconciler := &GithubIssuerReconciler{Client: c, Scheme: c.Scheme(), GitHubClients: &github_utils.ClientSet{Transport: mockedHTTPClient.Transport}}
+			result, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+			Expect(err).Should(BeNil())
+			Expect(result.RequeueAfter).Should(BeNumerically(">", 0))
+			Expect(c.Get(ctx, key, githubIssuer)).Should(Succeed())
+			Expect(githubIssuer.Finalizers).Should(ContainElement(FinalizerName))
+		})
 		It("should successfully Create an issue", func() {
 			By("Creating the custom resource for the Kind GithubIssuer")
 			githubIssuer := &githubv1.GithubIssuer{
@@ -145,7 +194,7 @@ var _ = Describe("GithubIssuer controller", func() {
 					[]github.Issue{
 						{
 							Title:  github.String(ISSUE),
-							Body:   github.String(DESCRIPTION),
+							Body:   github.String(DESCRIPTION + "\n\n" + OWNER.String()),
 							Number: github.Int(NUMBER),
 							Repository: &github.Repository{
 								Name: github.String(REPO),
@@ -162,7 +211,7 @@ var _ = Describe("GithubIssuer controller", func() {
 				),
 			)
 			nclient := github.NewClient(mockedHTTPClient)
-			issue, err := github_utils.FetchIssue(githubIssuer.Spec.Repo, githubIssuer.Spec.Title, ctx, nclient)
+			issue, err := github_utils.FetchIssue(githubIssuer.Spec.Repo, OWNER, ctx, nclient)
 			Expect(issue != nil && err == nil).Should(BeTrue())
 
 		})
@@ -202,7 +251,7 @@ var _ = Describe("GithubIssuer controller", func() {
 					[]github.Issue{
 						{
 							Title:  github.String(ISSUE),
-							Body:   github.String(DESCRIPTION + "2"),
+							Body:   github.String(DESCRIPTION + "2\n\n" + OWNER.String()),
 							Number: github.Int(NUMBER),
 							Repository: &github.Repository{
 								Name: github.String(REPO),
@@ -219,10 +268,302 @@ var _ = Describe("GithubIssuer controller", func() {
 				),
 			)
 			nclient := github.NewClient(mockedHTTPClient)
-			issue, _ := github_utils.FetchIssue(githubIssuer.Spec.Repo, githubIssuer.Spec.Title, ctx, nclient)
-			Expect(*issue.Body == "test-body2").Should(BeTrue())
+			issue, _ := github_utils.FetchIssue(githubIssuer.Spec.Repo, OWNER, ctx, nclient)
+			Expect(strings.HasPrefix(*issue.Body, "test-body2")).Should(BeTrue())
 
 		})
 
 	})
+	Context("GithubIssuer conditions", func() {
+		It("should take the issue out of the milestone the spec dropped", func() {
+			reconciler := &GithubIssuerReconciler{}
+			githubIssuer := &githubv1.GithubIssuer{Spec: githubv1.GithubIssuerSpec{Milestone: "v1.0"}}
+			Expect(reconciler.issueOptions(githubIssuer).ClearMilestone).Should(BeFalse())
+			recordIssue(githubIssuer, nil)
+			githubIssuer.Spec.Milestone = ""
+			Expect(reconciler.issueOptions(githubIssuer).ClearMilestone).Should(BeTrue())
+			recordIssue(githubIssuer, nil)
+			Expect(reconciler.issueOptions(githubIssuer).ClearMilestone).Should(BeFalse())
+		})
+		It("should keep a single condition per type", func() {
+			githubIssuer := &githubv1.GithubIssuer{ObjectMeta: metav1.ObjectMeta{Generation: 2}}
+			setCondition(githubIssuer, ReadyCondition, metav1.ConditionFalse, "RateLimited", "rate limited")
+			setCondition(githubIssuer, ReadyCondition, metav1.ConditionTrue, "IssueSynced", "in sync")
+			Expect(githubIssuer.Status.Conditions).Should(HaveLen(1))
+			Expect(githubIssuer.Status.Conditions[0].Status).Should(Equal(metav1.ConditionTrue))
+			Expect(githubIssuer.Status.Conditions[0].ObservedGeneration).Should(Equal(int64(2)))
+		})
+		It("should give each GitHub error its own reason", func() {
+			Expect(errorReason(&github_utils.APIError{Kind: github_utils.ErrRepoNotFound})).Should(Equal("RepoNotFound"))
+			Expect(errorReason(&github_utils.APIError{Kind: github_utils.ErrRateLimited})).Should(Equal("RateLimited"))
+			Expect(errorReason(fmt.Errorf("connection refused"))).Should(Equal("GithubError"))
+		})
+		It("should tell when GitHub can't be reached", func() {
+			Expect(githubReachable(&github_utils.APIError{Kind: github_utils.ErrForbidden})).Should(BeTrue())
+			Expect(githubReachable(&github_utils.APIError{Kind: github_utils.ErrUnauthorized})).Should(BeFalse())
+			Expect(githubReachable(fmt.Errorf("connection refused"))).Should(BeFalse())
+		})
+		It("should requeue rate limited syncs for when the limit resets", func() {
+			result, err := syncResult(&github_utils.APIError{Kind: github_utils.ErrRateLimited, RetryAt: time.Now().Add(time.Minute)})
+			Expect(err).Should(BeNil())
+			Expect(result.RequeueAfter).Should(BeNumerically("~", time.Minute, time.Second))
+			_, err = syncResult(&github_utils.APIError{Kind: github_utils.ErrForbidden})
+			Expect(err).ShouldNot(BeNil())
+		})
+		It("should resync at the interval of the GithubIssuer, with jitter", func() {
+			reconciler := &GithubIssuerReconciler{ResyncInterval: time.Minute}
+			githubIssuer := &githubv1.GithubIssuer{}
+			Expect(reconciler.resyncAfter(githubIssuer)).Should(BeNumerically("~", time.Minute+3*time.Second, 3*time.Second))
+			githubIssuer.Spec.ResyncInterval = &metav1.Duration{Duration: time.Hour}
+			Expect(reconciler.resyncAfter(githubIssuer)).Should(BeNumerically("~", time.Hour+3*time.Minute, 3*time.Minute))
+			githubIssuer.Spec.ResyncInterval = nil
+			reconciler.ResyncInterval = 0
+			Expect(reconciler.resyncAfter(githubIssuer)).Should(BeZero())
+		})
+		It("should only tell whether the assignees were assigned while they're managed", func() {
+			githubIssuer := &githubv1.GithubIssuer{Spec: githubv1.GithubIssuerSpec{Assignees: []string{"outside-user"}}}
+			applySyncResult(githubIssuer, github_utils.SyncResult{UnassignableAssignees: []string{"outside-user"}})
+			Expect(meta.IsStatusConditionFalse(githubIssuer.Status.Conditions, AssigneesAssignableCondition)).Should(BeTrue())
+			githubIssuer.Spec.Assignees = nil
+			applySyncResult(githubIssuer, github_utils.SyncResult{})
+			Expect(meta.FindStatusCondition(githubIssuer.Status.Conditions, AssigneesAssignableCondition)).Should(BeNil())
+			Expect(githubIssuer.Status.UnassignableAssignees).Should(BeEmpty())
+		})
+		It("should keep the key of a write across its replays", func() {
+			ctx := context.Background()
+			githubIssuer := &githubv1.GithubIssuer{ObjectMeta: metav1.ObjectMeta{Namespace: "write-key", Name: "write-key"}}
+			c := fake.NewClientBuilder().WithScheme(k8sClient.Scheme()).WithObjects(githubIssuer).Build()
+			reconciler := &GithubIssuerReconciler{Client: c}
+			Expect(reconciler.beginWrite(ctx, githubIssuer, githubv1.WriteCreate, 0)).Should(Succeed())
+			key := githubIssuer.Status.PendingWrite.Key
+			Expect(key).ShouldNot(BeEmpty())
+			Expect(reconciler.beginWrite(ctx, githubIssuer, githubv1.WriteCreate, 0)).Should(Succeed())
+			Expect(githubIssuer.Status.PendingWrite.Key).Should(Equal(key))
+			Expect(reconciler.beginWrite(ctx, githubIssuer, githubv1.WriteUpdate, 1)).Should(Succeed())
+			Expect(githubIssuer.Status.PendingWrite.Key).ShouldNot(Equal(key))
+		})
+		It("should keep a write pending until its outcome is known", func() {
+			githubIssuer := &githubv1.GithubIssuer{}
+			githubIssuer.Status.PendingWrite = &githubv1.PendingWrite{Operation: githubv1.WriteCreate, StartedAt: metav1.Now()}
+			Expect(pendingCreateWait(githubIssuer)).Should(BeNumerically(">", createSettleTime-time.Second))
+			settleWrite(githubIssuer, fmt.Errorf("connection reset"))
+			Expect(githubIssuer.Status.PendingWrite).ShouldNot(BeNil())
+			githubIssuer.Status.PendingWrite.StartedAt = metav1.NewTime(time.Now().Add(-time.Minute))
+			Expect(pendingCreateWait(githubIssuer)).Should(BeZero())
+			settleWrite(githubIssuer, &github_utils.APIError{Kind: github_utils.ErrValidationFailed})
+			Expect(githubIssuer.Status.PendingWrite).Should(BeNil())
+		})
+		It("should only remember access checks that tell something about the repo", func() {
+			Expect(accessDecided(nil)).Should(BeTrue())
+			Expect(accessDecided(fmt.Errorf("%w: test-user/test-repo", github_utils.ErrIssueWriteDenied))).Should(BeTrue())
+			Expect(accessDecided(&github_utils.APIError{Kind: github_utils.ErrRepoNotFound})).Should(BeTrue())
+			Expect(accessDecided(&github_utils.APIError{Kind: github_utils.ErrRateLimited})).Should(BeFalse())
+			Expect(accessDecided(fmt.Errorf("connection refused"))).Should(BeFalse())
+			Expect(errorReason(fmt.Errorf("%w: test-user/test-repo", github_utils.ErrIssuesDisabled))).Should(Equal("IssuesDisabled"))
+		})
+	})
+	Context("Shards", func() {
+		It("should split the shards between the replicas and take them over when one leaves", func() {
+			ctx := context.Background()
+			namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "shards"}}
+			Expect(k8sClient.Create(ctx, namespace)).Should(Succeed())
+			newShards := func(identity string) *Shards {
+				return &Shards{Client: k8sClient, APIReader: k8sClient, Name: "github-issuer", Namespace: namespace.Name, Identity: identity, Count: 4}
+			}
+			first, second := newShards("first"), newShards("second")
+			Expect(first.sync(ctx)).Should(Succeed())
+			Expect(first.held).Should(HaveLen(4))
+			// A reconcile running in a shard let go of keeps its Lease.
+			var running *githubv1.GithubIssuer
+			for _, name := range []string{"a", "b", "c", "d", "e"} {
+				githubIssuer := &githubv1.GithubIssuer{ObjectMeta: metav1.ObjectMeta{Namespace: name}}
+				if first.shardOf(githubIssuer) < 2 {
+					running = githubIssuer
+				}
+			}
+			Expect(running).ShouldNot(BeNil())
+			done, owned := first.begin(running)
+			Expect(owned).Should(BeTrue())
+			Expect(second.sync(ctx)).Should(Succeed())
+			Expect(first.sync(ctx)).Should(Succeed())
+			Expect(first.held).Should(HaveLen(2))
+			Expect(first.Owns(running)).Should(BeFalse())
+			Expect(first.sync(ctx)).Should(Succeed())
+			Expect(second.sync(ctx)).Should(Succeed())
+			Expect(second.held).Should(HaveLen(1))
+			done()
+			Expect(first.sync(ctx)).Should(Succeed())
+			Expect(second.sync(ctx)).Should(Succeed())
+			Expect(first.held).Should(HaveLen(2))
+			Expect(second.held).Should(HaveLen(2))
+			Expect(second.Owns(running)).Should(BeTrue())
+			for _, name := range []string{"a", "b", "c", "d", "e"} {
+				githubIssuer := &githubv1.GithubIssuer{ObjectMeta: metav1.ObjectMeta{Namespace: name}}
+				Expect(first.Owns(githubIssuer)).ShouldNot(Equal(second.Owns(githubIssuer)))
+			}
+			first.releaseAll(logr.Discard())
+			Expect(second.sync(ctx)).Should(Succeed())
+			Expect(second.held).Should(HaveLen(4))
+		})
+		It("should reconcile the GithubIssuers of a shard again once a late renewal goes through", func() {
+			ctx := context.Background()
+			githubIssuer := &githubv1.GithubIssuer{ObjectMeta: metav1.ObjectMeta{Namespace: "shards-late", Name: "late"}}
+			c := fake.NewClientBuilder().WithScheme(k8sClient.Scheme()).WithObjects(githubIssuer).Build()
+			shards := &Shards{Client: c, APIReader: c, Name: "github-issuer", Namespace: "shards-late", Identity: "late", Count: 1}
+			Expect(shards.sync(ctx)).Should(Succeed())
+			Expect(shards.Owns(githubIssuer)).Should(BeTrue())
+			shards.held[0] = time.Now().Add(-shards.leaseDuration())
+			Expect(shards.Owns(githubIssuer)).Should(BeFalse())
+			shards.events = make(chan event.GenericEvent, 1)
+			Expect(shards.sync(ctx)).Should(Succeed())
+			Expect(shards.Owns(githubIssuer)).Should(BeTrue())
+			Eventually(shards.events).Should(Receive())
+		})
+		It("should delete the member Leases of replicas that crashed", func() {
+			ctx := context.Background()
+			seconds := int32(15)
+			renewed := func(ago time.Duration) *metav1.MicroTime {
+				t := metav1.NewMicroTime(time.Now().Add(-ago))
+				return &t
+			}
+			member := func(identity string, renewTime *metav1.MicroTime) *coordinationv1.Lease {
+				return &coordinationv1.Lease{
+					ObjectMeta: metav1.ObjectMeta{Namespace: "shards-gone", Name: "github-issuer-member-" + identity, Labels: map[string]string{shardMemberLabel: "github-issuer"}},
+					Spec:       coordinationv1.LeaseSpec{HolderIdentity: &identity, LeaseDurationSeconds: &seconds, RenewTime: renewTime},
+				}
+			}
+			c := fake.NewClientBuilder().WithScheme(k8sClient.Scheme()).WithObjects(member("crashed", renewed(time.Hour)), member("late", renewed(30*time.Second))).Build()
+			shards := &Shards{Client: c, APIReader: c, Name: "github-issuer", Namespace: "shards-gone", Identity: "alive", Count: 1}
+			Expect(shards.sync(ctx)).Should(Succeed())
+			var leases coordinationv1.LeaseList
+			Expect(c.List(ctx, &leases, client.MatchingLabels{shardMemberLabel: "github-issuer"})).Should(Succeed())
+			var names []string
+			for _, lease := range leases.Items {
+				names = append(names, lease.Name)
+			}
+			Expect(names).Should(ConsistOf("github-issuer-member-alive", "github-issuer-member-late"))
+		})
+		It("should hash the GithubIssuers onto the shards by namespace or repo", func() {
+			shards := &Shards{Count: 8, By: ShardByRepo}
+			githubIssuer := &githubv1.GithubIssuer{ObjectMeta: metav1.ObjectMeta{Namespace: "a"}, Spec: githubv1.GithubIssuerSpec{Repo: "https://github.com/User/Repo"}}
+			other := &githubv1.GithubIssuer{ObjectMeta: metav1.ObjectMeta{Namespace: "b"}, Spec: githubv1.GithubIssuerSpec{Repo: "https://github.com/user/repo/"}}
+			Expect(shards.shardOf(githubIssuer)).Should(Equal(shards.shardOf(other)))
+			Expect(shards.shardOf(githubIssuer)).Should(BeNumerically("<", 8))
+			Expect(shardShare(8, 3)).Should(Equal(3))
+			Expect(shardShare(8, 0)).Should(Equal(8))
+			Expect((*Shards)(nil).Owns(githubIssuer)).Should(BeTrue())
+		})
+	})
+	Context("GithubConnection credentials", func() {
+		It("should read a token", func() {
+			creds, err := secretCredentials(&corev1.Secret{Data: map[string][]byte{githubv1.ConnectionSecretToken: []byte("token\n")}})
+			Expect(err).Should(BeNil())
+			Expect(creds.Token).Should(Equal("token"))
+			Expect(creds.App).Should(BeNil())
+		})
+		It("should read the credentials of a GitHub App", func() {
+			creds, err := secretCredentials(&corev1.Secret{Data: map[string][]byte{
+				githubv1.ConnectionSecretAppID:          []byte("42"),
+				githubv1.ConnectionSecretPrivateKey:     []byte("key"),
+				githubv1.ConnectionSecretInstallationID: []byte("7"),
+			}})
+			Expect(err).Should(BeNil())
+			Expect(creds.App.AppID).Should(Equal(int64(42)))
+			Expect(creds.App.InstallationID).Should(Equal(int64(7)))
+		})
+		It("should pool several tokens", func() {
+			creds, err := secretCredentials(&corev1.Secret{Data: map[string][]byte{githubv1.ConnectionSecretToken: []byte("first\nsecond\n")}})
+			Expect(err).Should(BeNil())
+			Expect(creds.Pool).Should(HaveLen(2))
+			Expect(creds.Pool[1].Token).Should(Equal("second"))
+		})
+		It("should reject a Secret without credentials", func() {
+			_, err := secretCredentials(&corev1.Secret{})
+			Expect(err).ShouldNot(BeNil())
+			_, err = secretCredentials(&corev1.Secret{Data: map[string][]byte{githubv1.ConnectionSecretAppID: []byte("app")}})
+			Expect(err).ShouldNot(BeNil())
+		})
+		It("should use the API the connection selects", func() {
+			ctx := context.Background()
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "graphql-bot", Namespace: "default"},
+				Data:       map[string][]byte{githubv1.ConnectionSecretToken: []byte("token")},
+			}
+			Expect(k8sClient.Create(ctx, secret)).Should(Succeed())
+			defer k8sClient.Delete(ctx, secret)
+			githubConnection := &githubv1.GithubConnection{
+				ObjectMeta: metav1.ObjectMeta{Name: "graphql-bot", Namespace: "default"},
+				Spec: githubv1.GithubConnectionSpec{
+					SecretRef: corev1.LocalObjectReference{Name: "graphql-bot"},
+					API:       githubv1.ConnectionAPIGraphQL,
+				},
+			}
+			Expect(k8sClient.Create(ctx, githubConnection)).Should(Succeed())
+			defer k8sClient.Delete(ctx, githubConnection)
+			githubIssuer := &githubv1.GithubIssuer{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-githubissuer", Namespace: "default"},
+				Spec:       githubv1.GithubIssuerSpec{Repo: "https://github.com/test-user/test-repo", ConnectionRef: &githubv1.ConnectionReference{Name: "graphql-bot"}},
+			}
+			reconciler := &GithubIssuerReconciler{Client: k8sClient, GitHubClients: &github_utils.ClientSet{}}
+			_, backend, err := reconciler.githubClient(ctx, githubIssuer)
+			Expect(err).Should(BeNil())
+			Expect(backend).Should(Equal(github_utils.GraphQL))
+		})
+		It("should report a missing connection", func() {
+			githubIssuer := &githubv1.GithubIssuer{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-githubissuer", Namespace: "default"},
+				Spec:       githubv1.GithubIssuerSpec{Repo: "https://github.com/test-user/test-repo", ConnectionRef: &githubv1.ConnectionReference{Name: "missing"}},
+			}
+			reconciler := &GithubIssuerReconciler{Client: k8sClient, GitHubClients: &github_utils.ClientSet{}}
+			_, _, err := reconciler.githubClient(context.Background(), githubIssuer)
+			Expect(err).Should(MatchError(ErrConnectionInvalid))
+			Expect(errorReason(err)).Should(Equal("ConnectionInvalid"))
+		})
+		It("should refuse a ClusterGithubConnection in a namespace it doesn't select", func() {
+			ctx := context.Background()
+			clusterConnection := &githubv1.ClusterGithubConnection{
+				ObjectMeta: metav1.ObjectMeta{Name: "shared-bot"},
+				Spec: githubv1.ClusterGithubConnectionSpec{
+					SecretRef:         corev1.SecretReference{Name: "github-bot", Namespace: "default"},
+					NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"shared-bot": "true"}},
+				},
+			}
+			Expect(k8sClient.Create(ctx, clusterConnection)).Should(Succeed())
+			defer k8sClient.Delete(ctx, clusterConnection)
+			githubIssuer := &githubv1.GithubIssuer{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-githubissuer", Namespace: "default"},
+				Spec: githubv1.GithubIssuerSpec{
+					Repo:          "https://github.com/test-user/test-repo",
+					ConnectionRef: &githubv1.ConnectionReference{Kind: githubv1.ConnectionKindCluster, Name: "shared-bot"},
+				},
+			}
+			reconciler := &GithubIssuerReconciler{Client: k8sClient, GitHubClients: &github_utils.ClientSet{}}
+			_, _, err := reconciler.githubClient(ctx, githubIssuer)
+			Expect(err).Should(MatchError(ErrConnectionNotAllowed))
+			Expect(errorReason(err)).Should(Equal("ConnectionNotAllowed"))
+		})
+	})
+	Context("Concurrent reconciles", func() {
+		It("should run the reconciles of the same issue one at a time", func() {
+			var locks issueLocks
+			unlock := locks.lock("https://github.com/test-user/test-repo", "test-title")
+			locked := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				unlockSame := locks.lock("https://github.com/Test-User/test-repo", "test-title")
+				close(locked)
+				unlockSame()
+			}()
+			unlockOther := locks.lock("https://github.com/test-user/test-repo", "other-title")
+			unlockOther()
+			Consistently(locked, 100*time.Millisecond).ShouldNot(BeClosed())
+			unlock()
+			Eventually(locked).Should(BeClosed())
+			Eventually(func() int {
+				locks.mu.Lock()
+				defer locks.mu.Unlock()
+				return len(locks.locks)
+			}).Should(BeZero())
+		})
+	})
 })
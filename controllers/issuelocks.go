@@ -0,0 +1,64 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"strings"
+	"sync"
+)
+
+// issueLocks serializes the reconciles that target the same issue, by repo
+// and title, so that concurrent workers can't both decide to create it.
+type issueLocks struct {
+	mu    sync.Mutex
+	locks map[string]*issueLock
+}
+
+type issueLock struct {
+	sync.Mutex
+	// holders counts the reconciles holding or waiting for the lock, it's
+	// dropped once there are none.
+	holders int
+}
+
+// lock blocks until no other reconcile targets the issue, and returns the
+// function that lets the next one go.
+func (l *issueLocks) lock(repo string, title string) func() {
+	key := strings.ToLower(strings.TrimSuffix(repo, "/")) + "\n" + title
+	l.mu.Lock()
+	if l.locks == nil {
+		l.locks = map[string]*issueLock{}
+	}
+	lock, ok := l.locks[key]
+	if !ok {
+		lock = &issueLock{}
+		l.locks[key] = lock
+	}
+	lock.holders++
+	l.mu.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		lock.holders--
+		if lock.holders == 0 {
+			delete(l.locks, key)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	githubv1 "github.com/github-issuer/api/v1"
+	"github.com/github-issuer/pkg/github_utils"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/uuid"
+)
+
+// createSettleTime is how long a create whose outcome was lost is held back.
+// The listings of GitHub can lag a write by a few seconds, the issue is only
+// created again once they had time to show it.
+const createSettleTime = 10 * time.Second
+
+// beginWrite records the write in the status before it's sent to GitHub. A
+// new write gets a key of its own, when the same write is already pending
+// this is a replay of it, which keeps the key of the first attempt.
+func (r *GithubIssuerReconciler) beginWrite(ctx context.Context, githubIssuer *githubv1.GithubIssuer, op githubv1.WriteOperation, number int) error {
+	key := string(uuid.NewUUID())
+	if pending := githubIssuer.Status.PendingWrite; pending != nil && pending.Operation == op && pending.IssueNumber == number && pending.Key != "" {
+		key = pending.Key
+	}
+	githubIssuer.Status.PendingWrite = &githubv1.PendingWrite{
+		Operation:   op,
+		Key:         key,
+		IssueNumber: number,
+		Generation:  githubIssuer.Generation,
+		StartedAt:   metav1.Now(),
+	}
+	return r.Status().Update(ctx, githubIssuer)
+}
+
+// settleWrite drops the pending write once its outcome is known: it went
+// through, or GitHub turned it down. A write that may or may not have been
+// made stays pending for the next reconcile to check.
+func settleWrite(githubIssuer *githubv1.GithubIssuer, err error) {
+	if err == nil || github_utils.WriteRejected(err) {
+		githubIssuer.Status.PendingWrite = nil
+	}
+}
+
+// pendingCreateWait returns how long to wait before creating the issue again
+// when a create whose outcome was lost is pending and its issue wasn't found.
+func pendingCreateWait(githubIssuer *githubv1.GithubIssuer) time.Duration {
+	pending := githubIssuer.Status.PendingWrite
+	if pending == nil || pending.Operation != githubv1.WriteCreate {
+		return 0
+	}
+	if wait := createSettleTime - time.Since(pending.StartedAt.Time); wait > 0 {
+		return wait
+	}
+	return 0
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/github"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	githubv1 "github.com/github-issuer/api/v1"
+	"github.com/github-issuer/pkg/github_utils"
+)
+
+const (
+	// accessCheckInterval is how long a repo stays accessible before it's checked again.
+	accessCheckInterval = 10 * time.Minute
+	// accessRecheckInterval is how long a repo stays inaccessible before it's checked again.
+	accessRecheckInterval = time.Minute
+)
+
+// accessCache remembers the outcome of the access checks of each client and
+// repo, so that a repo is only checked on its first use and every once in a
+// while after that. A client built from new credentials starts over.
+type accessCache struct {
+	mu      sync.Mutex
+	results map[accessKey]accessResult
+}
+
+type accessKey struct {
+	client *github.Client
+	repo   string
+}
+
+type accessResult struct {
+	err       error
+	expiresAt time.Time
+}
+
+func (c *accessCache) check(ctx context.Context, repo string, githubClient *github.Client) error {
+	key := accessKey{client: githubClient, repo: strings.ToLower(repo)}
+	c.mu.Lock()
+	result, ok := c.results[key]
+	c.mu.Unlock()
+	if ok && time.Now().Before(result.expiresAt) {
+		return result.err
+	}
+	err := github_utils.CheckRepoAccess(repo, ctx, githubClient)
+	if !accessDecided(err) {
+		return err
+	}
+	ttl := accessCheckInterval
+	if err != nil {
+		ttl = accessRecheckInterval
+	}
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for cachedKey, cached := range c.results {
+		if now.After(cached.expiresAt) {
+			delete(c.results, cachedKey)
+		}
+	}
+	if c.results == nil {
+		c.results = map[accessKey]accessResult{}
+	}
+	c.results[key] = accessResult{err: err, expiresAt: now.Add(ttl)}
+	return err
+}
+
+// accessDecided tells whether the outcome of an access check says anything
+// about the repo, rather than GitHub being unreachable or rate limited.
+func accessDecided(err error) bool {
+	var apiErr *github_utils.APIError
+	if err == nil || errors.Is(err, github_utils.ErrIssuesDisabled) || errors.Is(err, github_utils.ErrIssueWriteDenied) {
+		return true
+	}
+	return errors.As(err, &apiErr) && !errors.Is(err, github_utils.ErrRateLimited)
+}
+
+// checkRepoAccess makes sure the repo of the GithubIssuer can be used and
+// records the outcome in the RepositoryAccessible condition.
+func (r *GithubIssuerReconciler) checkRepoAccess(ctx context.Context, githubIssuer *githubv1.GithubIssuer, githubClient *github.Client) error {
+	err := r.access.check(ctx, githubIssuer.Spec.Repo, githubClient)
+	switch {
+	case err == nil:
+		setCondition(githubIssuer, RepositoryAccessibleCondition, metav1.ConditionTrue, "Accessible", "The repo accepts issues from the controller")
+	case accessDecided(err):
+		setCondition(githubIssuer, RepositoryAccessibleCondition, metav1.ConditionFalse, errorReason(err), err.Error())
+	}
+	return err
+}
@@ -0,0 +1,480 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"sync"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	githubv1 "github.com/github-issuer/api/v1"
+	"github.com/go-logr/logr"
+)
+
+// What the GithubIssuers are split between the shards by.
+const (
+	ShardByNamespace = "namespace"
+	ShardByRepo      = "repo"
+)
+
+const (
+	// shardMemberLabel marks the Leases the replicas hold to tell they're
+	// alive, its value is the name of the shards.
+	shardMemberLabel = "github.benda.io/shard-member"
+	// defaultShardLeaseDuration is how long a Lease is held without being
+	// renewed, when Shards doesn't set it.
+	defaultShardLeaseDuration = 15 * time.Second
+	// staleMemberLeaseAge is how many Lease durations a member Lease stays
+	// expired before it's deleted.
+	staleMemberLeaseAge = 4
+)
+
+// Shards splits the GithubIssuers between the replicas of the controller, so
+// that they all work instead of one leader. The GithubIssuers are hashed by
+// namespace or repo onto a fixed number of shards, each reconciled by the
+// replica holding its Lease. Every replica also holds a Lease of its own,
+// from which the replicas count each other: a replica takes free shards
+// until it holds its share of them and lets go of those above it, so the
+// shards spread out again when replicas join or leave. A nil Shards owns
+// every GithubIssuer.
+type Shards struct {
+	// Client writes the Leases and lists the GithubIssuers of a shard taken over.
+	Client client.Client
+	// APIReader reads the Leases, so that they don't have to be cached.
+	APIReader client.Reader
+	// Name prefixes the names of the Leases.
+	Name string
+	// Namespace is where the Leases are.
+	Namespace string
+	// Identity tells the replica apart from the others.
+	Identity string
+	// Count is the number of shards. It must be the same on every replica.
+	Count int
+	// By is what the GithubIssuers are hashed by, ShardByNamespace or ShardByRepo.
+	By string
+	// LeaseDuration is how long a Lease is held without being renewed. A
+	// third of it is waited between renewals, 15s when it's 0.
+	LeaseDuration time.Duration
+
+	mu sync.Mutex
+	// held maps the shards the replica holds to when their Lease was last renewed.
+	held map[int]time.Time
+	// draining are the shards the replica let go of whose Lease it keeps
+	// until the reconciles still running in them are done.
+	draining map[int]bool
+	// running counts the reconciles running in each shard.
+	running map[int]int
+	events  chan event.GenericEvent
+}
+
+// Owns reports whether the GithubIssuer is in a shard the replica holds. A
+// shard stops being owned a renewal before its Lease runs out, so that the
+// replica taking it over doesn't start while this one still works on it.
+func (s *Shards) Owns(githubIssuer *githubv1.GithubIssuer) bool {
+	if s == nil {
+		return true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.owns(s.shardOf(githubIssuer))
+}
+
+// begin reports whether the GithubIssuer is in a shard the replica holds and
+// if so counts a reconcile of it as running in the shard until done is
+// called. The Lease of a shard let go of is only released once none runs.
+func (s *Shards) begin(githubIssuer *githubv1.GithubIssuer) (done func(), owned bool) {
+	if s == nil {
+		return func() {}, true
+	}
+	shard := s.shardOf(githubIssuer)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.owns(shard) {
+		return nil, false
+	}
+	if s.running == nil {
+		s.running = map[int]int{}
+	}
+	s.running[shard]++
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.running[shard]--; s.running[shard] == 0 {
+			delete(s.running, shard)
+		}
+	}, true
+}
+
+// owns must be called with s.mu held.
+func (s *Shards) owns(shard int) bool {
+	renewedAt, ok := s.held[shard]
+	return ok && time.Since(renewedAt) < s.leaseDuration()-s.renewInterval()
+}
+
+// NeedLeaderElection tells the manager to run the shards on every replica.
+func (s *Shards) NeedLeaderElection() bool {
+	return false
+}
+
+// Start holds the Leases of the replica until the context is done, then gives
+// them up for the other replicas to take over right away.
+func (s *Shards) Start(ctx context.Context) error {
+	log := ctrllog.FromContext(ctx).WithName("shards").WithValues("identity", s.Identity)
+	ticker := time.NewTicker(s.renewInterval())
+	defer ticker.Stop()
+	for {
+		if err := s.sync(ctx); err != nil {
+			log.Error(err, "unable to sync the shard Leases")
+		}
+		select {
+		case <-ctx.Done():
+			s.releaseAll(log)
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// source returns the source of the events for the GithubIssuers of the shards
+// the replica takes over.
+func (s *Shards) source() source.Source {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.events == nil {
+		s.events = make(chan event.GenericEvent)
+	}
+	return &source.Channel{Source: s.events}
+}
+
+// sync renews the Lease of the replica, counts the live replicas, and takes
+// or lets go of shards to hold its share of them. A shard let go of stops
+// being owned right away, but its Lease is kept until the reconciles running
+// in it are done, at the earliest on the next sync, so that the replica
+// taking it over doesn't work on the same GithubIssuers at the same time.
+// The member Leases left behind by replicas that crashed are deleted.
+func (s *Shards) sync(ctx context.Context) error {
+	log := ctrllog.FromContext(ctx).WithName("shards").WithValues("identity", s.Identity)
+	now := time.Now()
+	if _, err := s.hold(ctx, s.memberLease(), true, now); err != nil {
+		return fmt.Errorf("unable to renew the member Lease: %w", err)
+	}
+	var leases coordinationv1.LeaseList
+	if err := s.APIReader.List(ctx, &leases, client.InNamespace(s.Namespace), client.MatchingLabels{shardMemberLabel: s.Name}); err != nil {
+		return fmt.Errorf("unable to list the member Leases: %w", err)
+	}
+	members := 0
+	for i := range leases.Items {
+		lease := &leases.Items[i]
+		if !leaseExpired(lease, now) {
+			members++
+			continue
+		}
+		if lease.Name != s.memberLease() && leaseExpired(lease, now.Add(-staleMemberLeaseAge*s.leaseDuration())) {
+			// Its replica crashed without deleting it, the identity of a
+			// replica changes on every start so nobody renews it again.
+			rv := lease.ResourceVersion
+			if err := s.Client.Delete(ctx, lease, client.Preconditions{ResourceVersion: &rv}); client.IgnoreNotFound(err) != nil && !k8serrors.IsConflict(err) {
+				log.Error(err, "unable to delete the member Lease of a gone replica", "lease", lease.Name)
+			}
+		}
+	}
+	share := shardShare(s.Count, members)
+	var taken []int
+	for shard := 0; shard < s.Count; shard++ {
+		s.mu.Lock()
+		_, held := s.held[shard]
+		owned := s.owns(shard)
+		draining := s.draining[shard]
+		running := s.running[shard]
+		count := len(s.held)
+		s.mu.Unlock()
+		if draining {
+			if running > 0 {
+				if _, err := s.hold(ctx, s.shardLease(shard), false, now); err != nil {
+					log.Error(err, "unable to hold the Lease of a shard let go of", "shard", shard)
+				}
+				continue
+			}
+			s.mu.Lock()
+			delete(s.draining, shard)
+			s.mu.Unlock()
+			if err := s.release(ctx, s.shardLease(shard)); err != nil {
+				log.Error(err, "unable to release the shard Lease", "shard", shard)
+			}
+			continue
+		}
+		if held && count > share {
+			log.Info("letting go of a shard", "shard", shard, "share", share)
+			s.mu.Lock()
+			delete(s.held, shard)
+			if s.draining == nil {
+				s.draining = map[int]bool{}
+			}
+			s.draining[shard] = true
+			s.mu.Unlock()
+			continue
+		}
+		if !held && count >= share {
+			continue
+		}
+		ok, err := s.hold(ctx, s.shardLease(shard), false, now)
+		if err != nil {
+			// A shard that isn't renewed stops being owned once its renewal is due.
+			log.Error(err, "unable to hold the shard Lease", "shard", shard)
+			continue
+		}
+		switch {
+		case ok:
+			s.mu.Lock()
+			if s.held == nil {
+				s.held = map[int]time.Time{}
+			}
+			s.held[shard] = now
+			s.mu.Unlock()
+			switch {
+			case !held:
+				log.Info("took over a shard", "shard", shard, "share", share)
+				taken = append(taken, shard)
+			case !owned:
+				// Its GithubIssuers were skipped while the renewal was late,
+				// their resyncs were dropped along with them.
+				log.Info("renewed a shard whose renewal was late", "shard", shard)
+				taken = append(taken, shard)
+			}
+		case held:
+			log.Info("lost a shard", "shard", shard)
+			s.drop(shard)
+		}
+	}
+	if len(taken) > 0 {
+		go s.enqueue(ctx, taken)
+	}
+	return nil
+}
+
+// hold takes the Lease when it's free or already held by the replica, and
+// renews it. It reports whether the replica holds the Lease afterwards. A
+// missing Lease is created, labeled as a member one when member is set.
+func (s *Shards) hold(ctx context.Context, name string, member bool, now time.Time) (bool, error) {
+	var lease coordinationv1.Lease
+	err := s.APIReader.Get(ctx, types.NamespacedName{Namespace: s.Namespace, Name: name}, &lease)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return false, err
+	}
+	seconds := int32(s.leaseDuration() / time.Second)
+	renewTime := metav1.NewMicroTime(now)
+	if k8serrors.IsNotFound(err) {
+		lease = coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{Namespace: s.Namespace, Name: name},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       &s.Identity,
+				LeaseDurationSeconds: &seconds,
+				AcquireTime:          &renewTime,
+				RenewTime:            &renewTime,
+			},
+		}
+		if member {
+			lease.Labels = map[string]string{shardMemberLabel: s.Name}
+		}
+		if err := s.Client.Create(ctx, &lease); err != nil {
+			if k8serrors.IsAlreadyExists(err) {
+				// Another replica created it first.
+				return false, nil
+			}
+			return false, err
+		}
+		return true, nil
+	}
+	if lease.Spec.HolderIdentity != nil && *lease.Spec.HolderIdentity != s.Identity && !leaseExpired(&lease, now) {
+		return false, nil
+	}
+	if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity != s.Identity {
+		lease.Spec.HolderIdentity = &s.Identity
+		lease.Spec.AcquireTime = &renewTime
+	}
+	lease.Spec.LeaseDurationSeconds = &seconds
+	lease.Spec.RenewTime = &renewTime
+	if err := s.Client.Update(ctx, &lease); err != nil {
+		if k8serrors.IsConflict(err) {
+			// Another replica wrote it first, it's not known who holds it now.
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// release gives up the Lease if the replica holds it.
+func (s *Shards) release(ctx context.Context, name string) error {
+	var lease coordinationv1.Lease
+	if err := s.APIReader.Get(ctx, types.NamespacedName{Namespace: s.Namespace, Name: name}, &lease); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity != s.Identity {
+		return nil
+	}
+	lease.Spec.HolderIdentity = nil
+	lease.Spec.AcquireTime = nil
+	lease.Spec.RenewTime = nil
+	return s.Client.Update(ctx, &lease)
+}
+
+// releaseAll gives up the shards and the member Lease when the replica stops.
+// The Leases of shards with reconciles still running after a renew interval
+// are left to run out.
+func (s *Shards) releaseAll(log logr.Logger) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.renewInterval())
+	defer cancel()
+	s.mu.Lock()
+	shards := map[int]bool{}
+	for shard := range s.held {
+		shards[shard] = true
+	}
+	for shard := range s.draining {
+		shards[shard] = true
+	}
+	s.held, s.draining = nil, nil
+	s.mu.Unlock()
+	for len(shards) > 0 {
+		var drained []int
+		s.mu.Lock()
+		for shard := range shards {
+			if s.running[shard] == 0 {
+				drained = append(drained, shard)
+				delete(shards, shard)
+			}
+		}
+		s.mu.Unlock()
+		for _, shard := range drained {
+			if err := s.release(ctx, s.shardLease(shard)); err != nil {
+				log.Error(err, "unable to release the shard Lease", "shard", shard)
+			}
+		}
+		if len(shards) == 0 {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			log.Info("leaving the Leases of shards with running reconciles to run out", "shards", len(shards))
+			shards = nil
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	var lease coordinationv1.Lease
+	lease.Namespace, lease.Name = s.Namespace, s.memberLease()
+	if err := s.Client.Delete(ctx, &lease); client.IgnoreNotFound(err) != nil {
+		log.Error(err, "unable to delete the member Lease")
+	}
+}
+
+// enqueue reconciles the GithubIssuers of the shards the replica took over.
+func (s *Shards) enqueue(ctx context.Context, shards []int) {
+	taken := map[int]bool{}
+	for _, shard := range shards {
+		taken[shard] = true
+	}
+	var githubIssuers githubv1.GithubIssuerList
+	if err := s.Client.List(ctx, &githubIssuers); err != nil {
+		ctrllog.FromContext(ctx).Error(err, "unable to list the GithubIssuers of the shards taken over", "shards", shards)
+		return
+	}
+	s.mu.Lock()
+	events := s.events
+	s.mu.Unlock()
+	if events == nil {
+		return
+	}
+	for i := range githubIssuers.Items {
+		if !taken[s.shardOf(&githubIssuers.Items[i])] {
+			continue
+		}
+		select {
+		case events <- event.GenericEvent{Object: &githubIssuers.Items[i]}:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *Shards) drop(shard int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.held, shard)
+}
+
+// shardOf hashes the namespace or the repo of the GithubIssuer onto a shard.
+func (s *Shards) shardOf(githubIssuer *githubv1.GithubIssuer) int {
+	key := githubIssuer.Namespace
+	if s.By == ShardByRepo {
+		key = strings.ToLower(strings.TrimSuffix(githubIssuer.Spec.Repo, "/"))
+	}
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(s.Count))
+}
+
+func (s *Shards) shardLease(shard int) string {
+	return fmt.Sprintf("%s-shard-%d", s.Name, shard)
+}
+
+func (s *Shards) memberLease() string {
+	return s.Name + "-member-" + s.Identity
+}
+
+func (s *Shards) leaseDuration() time.Duration {
+	if s.LeaseDuration <= 0 {
+		return defaultShardLeaseDuration
+	}
+	return s.LeaseDuration
+}
+
+func (s *Shards) renewInterval() time.Duration {
+	return s.leaseDuration() / 3
+}
+
+// shardShare is how many of the shards each of the live replicas holds at most.
+func shardShare(count int, members int) int {
+	if members < 1 {
+		members = 1
+	}
+	return (count + members - 1) / members
+}
+
+// leaseExpired reports whether the Lease is free: nobody holds it, or its
+// holder didn't renew it in time.
+func leaseExpired(lease *coordinationv1.Lease, now time.Time) bool {
+	if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity == "" || lease.Spec.RenewTime == nil {
+		return true
+	}
+	duration := defaultShardLeaseDuration
+	if lease.Spec.LeaseDurationSeconds != nil {
+		duration = time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second
+	}
+	return !now.Before(lease.Spec.RenewTime.Add(duration))
+}
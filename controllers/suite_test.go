@@ -18,6 +18,7 @@ package controllers
 
 import (
 	"context"
+	"net/http"
 	"path/filepath"
 	"testing"
 
@@ -27,6 +28,7 @@ import (
 	. "github.com/onsi/gomega"
 
 	githubv1 "github.com/github-issuer/api/v1"
+	"github.com/github-issuer/pkg/github_utils"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -56,6 +58,8 @@ const (
 	NUMBER            = 1
 )
 
+var OWNER = github_utils.Marker{ClusterID: "test-cluster", Namespace: "test-namespace", Name: "test-githubissuer", UID: "test-uid"}
+
 func TestAPIs(t *testing.T) {
 	RegisterFailHandler(Fail)
 
@@ -87,6 +91,16 @@ var _ = BeforeSuite(func() {
 	})
 	Expect(err).ToNot(HaveOccurred())
 	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatchHandler(
+			mock.GetReposByOwnerByRepo,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write(mock.MustMarshal(github.Repository{
+					FullName:    github.String(USER + "/" + REPO),
+					HasIssues:   github.Bool(true),
+					Permissions: &map[string]bool{"push": true},
+				}))
+			}),
+		),
 		mock.WithRequestMatch(
 			mock.GetReposIssuesByOwnerByRepo,
 			[]github.Issue{
@@ -205,9 +219,10 @@ var _ = BeforeSuite(func() {
 	issues, _, err := nclient.Issues.ListByRepo(ctx, "test-user", "test-repo", &opts)
 	GinkgoWriter.Println(issues)
 	err = (&GithubIssuerReconciler{
-		Client:       k8sManager.GetClient(),
-		Scheme:       k8sManager.GetScheme(),
-		GitHubClient: github.NewClient(mockedHTTPClient),
+		Client:        k8sManager.GetClient(),
+		Scheme:        k8sManager.GetScheme(),
+		GitHubClients: &github_utils.ClientSet{Transport: mockedHTTPClient.Transport},
+		ClusterID:     OWNER.ClusterID,
 	}).SetupWithManager(k8sManager)
 	Expect(err).ToNot(HaveOccurred())
 
@@ -22,23 +22,32 @@ import (
 	// to ensure that exec-entrypoint and run can make use of them.
 
 	"context"
+	"errors"
 	"flag"
+	"fmt"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"go.elastic.co/ecszap"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
 	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/uuid"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
 
 	githubv1 "github.com/github-issuer/api/v1"
 	"github.com/github-issuer/controllers"
 	"github.com/github-issuer/pkg/github_utils"
+	"github.com/go-logr/logr"
 	"github.com/go-logr/zapr"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	//+kubebuilder:scaffold:imports
@@ -60,11 +69,49 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var clusterID string
+	var issueRefreshInterval time.Duration
+	var maxConcurrentReconciles int
+	var resyncInterval time.Duration
+	var shardCount int
+	var shardBy string
+	var shardNamespace string
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.StringVar(&clusterID, "cluster-id", "",
+		"The ID written into the ownership marker of every issue. "+
+			"Defaults to the UID of the kube-system namespace.")
+	flag.DurationVar(&issueRefreshInterval, "issue-refresh-interval", time.Minute,
+		"How long the issues listed from a repo are used before the ones updated since are listed.")
+	flag.IntVar(&maxConcurrentReconciles, "max-concurrent-reconciles", 1,
+		"How many GithubIssuers are reconciled at once. Those of the same repo and title never are.")
+	flag.DurationVar(&resyncInterval, "resync-interval", time.Minute,
+		"How often a GithubIssuer that doesn't set spec.resyncInterval is synced when nothing changed, 0 for never.")
+	flag.IntVar(&shardCount, "shards", 0,
+		"How many shards the GithubIssuers are split into. With more than one, every replica reconciles the shards "+
+			"whose Lease it holds instead of one leader doing all the work, and --leader-elect is ignored.")
+	flag.StringVar(&shardBy, "shard-by", controllers.ShardByNamespace,
+		"What the GithubIssuers are hashed onto the shards by, namespace or repo. "+
+			"By repo, the issues of a repo are listed by a single replica.")
+	flag.StringVar(&shardNamespace, "shard-namespace", os.Getenv("POD_NAMESPACE"),
+		"The namespace of the shard Leases. Defaults to the POD_NAMESPACE environment variable.")
+	var transportOptions github_utils.TransportOptions
+	var caBundleFile string
+	flag.StringVar(&transportOptions.ProxyURL, "github-proxy-url", "",
+		"The HTTPS proxy to reach GitHub through. Defaults to the HTTPS_PROXY environment variable.")
+	flag.StringVar(&caBundleFile, "github-ca-bundle", "",
+		"A file of PEM encoded CA certificates trusted for GitHub on top of the system ones.")
+	flag.DurationVar(&transportOptions.Timeout, "github-timeout", 30*time.Second,
+		"The timeout of every request to GitHub, 0 for none.")
+	flag.IntVar(&transportOptions.MaxIdleConns, "github-max-idle-conns", 0,
+		"The maximum number of idle connections to GitHub, 0 for the default.")
+	flag.IntVar(&transportOptions.MaxIdleConnsPerHost, "github-max-idle-conns-per-host", 0,
+		"The maximum number of idle connections to each GitHub host, 0 for the default.")
+	flag.IntVar(&transportOptions.MaxConnsPerHost, "github-max-conns-per-host", 0,
+		"The maximum number of connections to each GitHub host, 0 for no limit.")
 	flag.Parse()
 
 	encoderConfig := ecszap.NewDefaultEncoderConfig()
@@ -72,7 +119,18 @@ func main() {
 	log := zap.New(core, zap.AddCaller())
 	logf.SetLogger(zapr.NewLogger(log))
 
-	minute := time.Minute
+	sharded := shardCount > 1
+	if sharded {
+		if shardBy != controllers.ShardByNamespace && shardBy != controllers.ShardByRepo {
+			setupLog.Error(fmt.Errorf("unknown --shard-by %q", shardBy), "unable to shard the GithubIssuers")
+			os.Exit(1)
+		}
+		if shardNamespace == "" {
+			setupLog.Error(errors.New("neither --shard-namespace nor POD_NAMESPACE is set"), "unable to shard the GithubIssuers")
+			os.Exit(1)
+		}
+		enableLeaderElection = false
+	}
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:                 scheme,
 		MetricsBindAddress:     metricsAddr,
@@ -80,22 +138,76 @@ func main() {
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
 		LeaderElectionID:       "8bfebfd7.benda.io",
-		SyncPeriod:             &minute,
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
 	ctx := context.Background()
-	client, err := github_utils.CreateClient(ctx, os.Getenv("GITHUB_PASSWORD"))
+	if clusterID == "" {
+		var kubeSystem corev1.Namespace
+		if err := mgr.GetAPIReader().Get(ctx, types.NamespacedName{Name: "kube-system"}, &kubeSystem); err != nil {
+			setupLog.Error(err, "unable to discover the cluster ID, set --cluster-id")
+			os.Exit(1)
+		}
+		clusterID = string(kubeSystem.UID)
+	}
+	creds, err := githubCredentials(mgr, "GITHUB")
 	if err != nil {
-		setupLog.Error(err, "unable to start GitHub client")
+		setupLog.Error(err, "unable to read GitHub credentials")
 		os.Exit(1)
 	}
+	if caBundleFile != "" {
+		if transportOptions.CABundle, err = os.ReadFile(caBundleFile); err != nil {
+			setupLog.Error(err, "unable to read the GitHub CA bundle")
+			os.Exit(1)
+		}
+	}
+	clients := &github_utils.ClientSet{Credentials: creds, Options: transportOptions}
+	if host := os.Getenv("GITHUB_ENTERPRISE_HOST"); host != "" {
+		enterpriseCreds, err := githubCredentials(mgr, "GITHUB_ENTERPRISE")
+		if err != nil {
+			setupLog.Error(err, "unable to read GitHub Enterprise credentials")
+			os.Exit(1)
+		}
+		clients.HostCredentials = map[string]github_utils.Credentials{host: enterpriseCreds}
+	}
+	for _, host := range githubHosts() {
+		if err := clients.CheckCredentials(ctx, host); err != nil {
+			if errors.Is(err, github_utils.ErrUnauthorized) {
+				setupLog.Error(err, "GitHub rejected the credentials", "host", host)
+				os.Exit(1)
+			}
+			setupLog.Error(err, "unable to check the GitHub credentials", "host", host)
+		}
+	}
+	var shards *controllers.Shards
+	if sharded {
+		hostname, err := os.Hostname()
+		if err != nil {
+			setupLog.Error(err, "unable to tell the identity of the replica")
+			os.Exit(1)
+		}
+		shards = &controllers.Shards{
+			Client:    mgr.GetClient(),
+			APIReader: mgr.GetAPIReader(),
+			Name:      "github-issuer",
+			Namespace: shardNamespace,
+			Identity:  strings.ToLower(hostname) + "-" + string(uuid.NewUUID())[:8],
+			Count:     shardCount,
+			By:        shardBy,
+		}
+	}
 	if err = (&controllers.GithubIssuerReconciler{
-		Client:       mgr.GetClient(),
-		Scheme:       mgr.GetScheme(),
-		GitHubClient: client,
+		Client:                  mgr.GetClient(),
+		Scheme:                  mgr.GetScheme(),
+		APIReader:               mgr.GetAPIReader(),
+		GitHubClients:           clients,
+		ClusterID:               clusterID,
+		Issues:                  &github_utils.IssueIndex{RefreshInterval: issueRefreshInterval},
+		MaxConcurrentReconciles: maxConcurrentReconciles,
+		ResyncInterval:          resyncInterval,
+		Shards:                  shards,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "GithubIssuer")
 		os.Exit(1)
@@ -117,3 +229,49 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// githubHosts returns the GitHub hosts the controller has credentials for.
+func githubHosts() []string {
+	hosts := []string{"github.com"}
+	if host := os.Getenv("GITHUB_ENTERPRISE_HOST"); host != "" {
+		hosts = append(hosts, host)
+	}
+	return hosts
+}
+
+// githubCredentials reads GitHub credentials from the environment variables
+// starting with prefix. When <prefix>_APP_ID is set the controller
+// authenticates as that GitHub App with the key in <prefix>_APP_PRIVATE_KEY.
+// Otherwise the token is read from the file in <prefix>_TOKEN_FILE, which is
+// watched for changes, or from <prefix>_PASSWORD. Several tokens or
+// installation IDs separated by commas are pooled.
+func githubCredentials(mgr ctrl.Manager, prefix string) (github_utils.Credentials, error) {
+	appID := os.Getenv(prefix + "_APP_ID")
+	if appID == "" {
+		tokenFile := os.Getenv(prefix + "_TOKEN_FILE")
+		if tokenFile == "" {
+			return github_utils.TokenCredentials(os.Getenv(prefix + "_PASSWORD")), nil
+		}
+		source, err := github_utils.NewFileTokenSource(tokenFile)
+		if err != nil {
+			return github_utils.Credentials{}, fmt.Errorf("unable to read %s_TOKEN_FILE: %w", prefix, err)
+		}
+		log := ctrl.Log.WithName("token-file").WithValues("path", tokenFile)
+		if err := mgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
+			return source.Start(logr.NewContext(ctx, log))
+		})); err != nil {
+			return github_utils.Credentials{}, err
+		}
+		return github_utils.Credentials{TokenSource: source}, nil
+	}
+	app := github_utils.AppCredentials{PrivateKey: []byte(os.Getenv(prefix + "_APP_PRIVATE_KEY"))}
+	var err error
+	if app.AppID, err = strconv.ParseInt(appID, 10, 64); err != nil {
+		return github_utils.Credentials{}, fmt.Errorf("invalid %s_APP_ID: %w", prefix, err)
+	}
+	creds, err := github_utils.AppInstallationCredentials(app, os.Getenv(prefix+"_APP_INSTALLATION_ID"))
+	if err != nil {
+		return github_utils.Credentials{}, fmt.Errorf("invalid %s_APP_INSTALLATION_ID: %w", prefix, err)
+	}
+	return creds, nil
+}
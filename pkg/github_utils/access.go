@@ -0,0 +1,98 @@
+package github_utils
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/google/go-github/github"
+)
+
+// issueWritePermissions are the repo permissions that allow editing,
+// labelling and closing issues.
+var issueWritePermissions = []string{"admin", "maintain", "push", "triage"}
+
+// CheckRepoAccess makes sure the repo exists, has issues enabled and that
+// the client's credentials may write its issues. Installation tokens of a
+// GitHub App don't get the permissions in the answer, for them only the
+// first two are checked.
+func CheckRepoAccess(repo string, ctx context.Context, client *github.Client) error {
+	githubAuth := divideUserAndRepo(repo)
+	repository, resp, err := client.Repositories.Get(ctx, githubAuth["user"], githubAuth["repo"])
+	if err != nil {
+		return classifyError(resp, err, ErrRepoNotFound)
+	}
+	if !repository.GetHasIssues() {
+		return fmt.Errorf("%w: %s", ErrIssuesDisabled, repository.GetFullName())
+	}
+	if repository.Permissions == nil {
+		return nil
+	}
+	for _, permission := range issueWritePermissions {
+		if (*repository.Permissions)[permission] {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrIssueWriteDenied, repository.GetFullName())
+}
+
+// CheckCredentials makes sure GitHub accepts the credentials used for the
+// host. A token is checked by looking up its user and a GitHub App by looking
+// up the app itself. Every member of a pool is checked on its own, since the
+// pool would otherwise only hit the few with budget left. There's nothing to
+// check without credentials.
+func (s *ClientSet) CheckCredentials(ctx context.Context, host string) error {
+	creds, ok := s.credentialsFor(host)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrHostUnknown, host)
+	}
+	if len(creds.Pool) == 0 {
+		return s.checkCredentials(ctx, host, creds)
+	}
+	for i, member := range creds.Pool {
+		if err := s.checkCredentials(ctx, host, member); err != nil {
+			return fmt.Errorf("member %d of the pool: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// checkCredentials checks a single set of credentials, outside of any pool.
+func (s *ClientSet) checkCredentials(ctx context.Context, host string, creds Credentials) error {
+	if creds.App == nil && creds.Token == "" && creds.TokenSource == nil {
+		return nil
+	}
+	apiURL, uploadURL := hostURLs(host)
+	baseURL, err := url.Parse(apiURL)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	transport, err := s.sharedTransport()
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if creds.App != nil {
+		app, err := newAppTransport(*creds.App, transport, baseURL)
+		if err != nil {
+			return err
+		}
+		_, resp, err := app.appClient.Apps.Get(ctx, "")
+		return classifyError(resp, err, ErrUnauthorized)
+	}
+	auth, err := authTransport(creds, transport, baseURL)
+	if err != nil {
+		return err
+	}
+	httpClient := &http.Client{Transport: auth, Timeout: s.Options.Timeout}
+	client := github.NewClient(httpClient)
+	if !isDefaultHost(host) {
+		if client, err = github.NewEnterpriseClient(apiURL, uploadURL, httpClient); err != nil {
+			return err
+		}
+	}
+	_, resp, err := client.Users.Get(ctx, "")
+	return classifyError(resp, err, ErrUnauthorized)
+}
@@ -0,0 +1,176 @@
+package github_utils
+
+import (
+	"context"
+	"crypto/rsa"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/go-github/github"
+)
+
+// tokenRefreshMargin is how long before it expires an installation token is replaced.
+const tokenRefreshMargin = 5 * time.Minute
+
+// AppCredentials authenticate as an installation of a GitHub App.
+type AppCredentials struct {
+	AppID int64
+	// PrivateKey is the PEM encoded private key of the app.
+	PrivateKey []byte
+	// InstallationID pins the installation to use. When it's 0 the
+	// installation is looked up for every repo owner the client talks to.
+	InstallationID int64
+}
+
+type installationToken struct {
+	token     string
+	expiresAt time.Time
+}
+
+// appTransport authenticates every request with a token of the app
+// installation that covers the repo owner of the request. Tokens are minted
+// on first use and refreshed shortly before they expire.
+type appTransport struct {
+	base           http.RoundTripper
+	appClient      *github.Client
+	installationID int64
+
+	mu            sync.Mutex
+	installations map[string]int64
+	tokens        map[int64]installationToken
+}
+
+// jwtTransport authenticates requests as the app itself, which is only
+// allowed for the endpoints that manage its installations.
+type jwtTransport struct {
+	base  http.RoundTripper
+	appID int64
+	key   *rsa.PrivateKey
+}
+
+func (t *jwtTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	now := time.Now()
+	claims := jwt.RegisteredClaims{
+		// Backdated to allow for clock drift between us and GitHub.
+		IssuedAt:  jwt.NewNumericDate(now.Add(-time.Minute)),
+		ExpiresAt: jwt.NewNumericDate(now.Add(9 * time.Minute)),
+		Issuer:    strconv.FormatInt(t.appID, 10),
+	}
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(t.key)
+	if err != nil {
+		return nil, err
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+signed)
+	return t.base.RoundTrip(req)
+}
+
+func newAppTransport(creds AppCredentials, base http.RoundTripper, baseURL *url.URL) (*appTransport, error) {
+	key, err := jwt.ParseRSAPrivateKeyFromPEM(creds.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse the private key of the GitHub App: %w", err)
+	}
+	appClient := github.NewClient(&http.Client{Transport: &jwtTransport{base: base, appID: creds.AppID, key: key}})
+	appClient.BaseURL = baseURL
+	return &appTransport{
+		base:           base,
+		appClient:      appClient,
+		installationID: creds.InstallationID,
+		installations:  map[string]int64{},
+		tokens:         map[int64]installationToken{},
+	}, nil
+}
+
+func (t *appTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.token(req)
+	if err != nil {
+		return nil, err
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "token "+token)
+	return t.base.RoundTrip(req)
+}
+
+// token returns a valid token of the installation covering the request.
+func (t *appTransport) token(req *http.Request) (string, error) {
+	ctx := req.Context()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	id, err := t.installation(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	if cached, ok := t.tokens[id]; ok && time.Until(cached.expiresAt) > tokenRefreshMargin {
+		return cached.token, nil
+	}
+	minted, resp, err := t.appClient.Apps.CreateInstallationToken(ctx, id)
+	if err != nil {
+		return "", classifyError(resp, err, ErrUnauthorized)
+	}
+	t.tokens[id] = installationToken{token: minted.GetToken(), expiresAt: minted.GetExpiresAt()}
+	return minted.GetToken(), nil
+}
+
+// installation returns the ID of the installation covering the request.
+func (t *appTransport) installation(ctx context.Context, req *http.Request) (int64, error) {
+	if t.installationID != 0 {
+		return t.installationID, nil
+	}
+	owner, repo := repoOfRequest(req)
+	if owner == "" {
+		return 0, fmt.Errorf("unable to tell which installation of the GitHub App covers %s, set its installation ID", req.URL.Path)
+	}
+	key := strings.ToLower(owner)
+	if id, ok := t.installations[key]; ok {
+		return id, nil
+	}
+	var installation *github.Installation
+	var resp *github.Response
+	var err error
+	if repo != "" {
+		installation, resp, err = t.appClient.Apps.FindRepositoryInstallation(ctx, owner, repo)
+	} else {
+		installation, resp, err = t.appClient.Apps.FindUserInstallation(ctx, owner)
+	}
+	if err != nil {
+		return 0, classifyError(resp, err, ErrRepoNotFound)
+	}
+	t.installations[key] = installation.GetID()
+	return installation.GetID(), nil
+}
+
+// repoOfRequest extracts the owner and repo a request is about, from the repo
+// a GraphQL request was sent for, or else the /repos/{owner}/{repo} path or
+// the repo: qualifier of a search of a REST request.
+func repoOfRequest(req *http.Request) (string, string) {
+	if repo, ok := req.Context().Value(graphqlRepoKey{}).(string); ok {
+		githubAuth := divideUserAndRepo(repo)
+		return githubAuth["user"], githubAuth["repo"]
+	}
+	u := req.URL
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	for i := 0; i+1 < len(segments); i++ {
+		if segments[i] != "repos" {
+			continue
+		}
+		if i+2 < len(segments) {
+			return segments[i+1], segments[i+2]
+		}
+		return segments[i+1], ""
+	}
+	for _, term := range strings.Fields(u.Query().Get("q")) {
+		if strings.HasPrefix(term, "repo:") {
+			split := strings.SplitN(strings.TrimPrefix(term, "repo:"), "/", 2)
+			if len(split) == 2 {
+				return split[0], split[1]
+			}
+		}
+	}
+	return "", ""
+}
@@ -0,0 +1,124 @@
+package github_utils
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/go-github/github"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const (
+	APP_ID          = 42
+	INSTALLATION_ID = 7
+)
+
+var _ = Describe("GitHub App authentication", func() {
+	var (
+		server     *httptest.Server
+		privateKey []byte
+		minted     int32
+		lifetime   time.Duration
+		issueAuth  string
+	)
+
+	BeforeEach(func() {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		Expect(err).Should(BeNil())
+		privateKey = pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+		atomic.StoreInt32(&minted, 0)
+		lifetime = time.Hour
+		issueAuth = ""
+		mux := http.NewServeMux()
+		mux.HandleFunc("/repos/"+USER+"/"+REPO+"/installation", func(w http.ResponseWriter, r *http.Request) {
+			Expect(r.Header.Get("Authorization")).Should(HavePrefix("Bearer "))
+			w.Write([]byte(`{"id": 7}`))
+		})
+		mux.HandleFunc("/installations/7/access_tokens", func(w http.ResponseWriter, r *http.Request) {
+			Expect(r.Header.Get("Authorization")).Should(HavePrefix("Bearer "))
+			n := atomic.AddInt32(&minted, 1)
+			expiresAt := time.Now().Add(lifetime).UTC().Format(time.RFC3339)
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"token": "installation-token-` + string(rune('0'+n)) + `", "expires_at": "` + expiresAt + `"}`))
+		})
+		mux.HandleFunc("/repos/"+USER+"/"+REPO+"/issues/1", func(w http.ResponseWriter, r *http.Request) {
+			issueAuth = r.Header.Get("Authorization")
+			w.Write([]byte(`{"number": 1, "title": "test-title"}`))
+		})
+		mux.HandleFunc("/graphql", func(w http.ResponseWriter, r *http.Request) {
+			issueAuth = r.Header.Get("Authorization")
+			w.Write([]byte(`{"data": {"repository": {"i1": {"id": "I_1", "number": 1, "title": "test-title", "state": "OPEN"}}}}`))
+		})
+		server = httptest.NewServer(mux)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	newClient := func(installationID int64) *github.Client {
+		baseURL, _ := url.Parse(server.URL + "/")
+		transport, err := newAppTransport(AppCredentials{AppID: APP_ID, PrivateKey: privateKey, InstallationID: installationID}, http.DefaultTransport, baseURL)
+		Expect(err).Should(BeNil())
+		c := github.NewClient(&http.Client{Transport: transport})
+		c.BaseURL = baseURL
+		return c
+	}
+
+	It("Should discover the installation of the repo owner and use its token", func() {
+		c := newClient(0)
+		_, err := GetIssue(REGULAR_URL, NUMBER, context.Background(), c)
+		Expect(err).Should(BeNil())
+		Expect(issueAuth).Should(Equal("token installation-token-1"))
+	})
+	It("Should discover the installation of the repo a GraphQL request is about", func() {
+		c := newClient(0)
+		issue, err := GraphQL.GetIssue(REGULAR_URL, NUMBER, context.Background(), c)
+		Expect(err).Should(BeNil())
+		Expect(issue.GetNumber()).Should(Equal(NUMBER))
+		Expect(issueAuth).Should(Equal("token installation-token-1"))
+	})
+	It("Should reuse the token until it's about to expire", func() {
+		c := newClient(INSTALLATION_ID)
+		for i := 0; i < 3; i++ {
+			_, err := GetIssue(REGULAR_URL, NUMBER, context.Background(), c)
+			Expect(err).Should(BeNil())
+		}
+		Expect(atomic.LoadInt32(&minted)).Should(Equal(int32(1)))
+	})
+	It("Should mint a new token when the old one is about to expire", func() {
+		lifetime = time.Minute
+		c := newClient(INSTALLATION_ID)
+		for i := 0; i < 2; i++ {
+			_, err := GetIssue(REGULAR_URL, NUMBER, context.Background(), c)
+			Expect(err).Should(BeNil())
+		}
+		Expect(atomic.LoadInt32(&minted)).Should(Equal(int32(2)))
+		Expect(issueAuth).Should(Equal("token installation-token-2"))
+	})
+	It("Should reject a malformed private key", func() {
+		_, err := CreateClient(context.Background(), "github.com", Credentials{App: &AppCredentials{AppID: APP_ID, PrivateKey: []byte("not a key")}})
+		Expect(err).ShouldNot(BeNil())
+	})
+	It("Should find the repo of a request", func() {
+		req, _ := http.NewRequest(http.MethodGet, "https://github.example.com/api/v3/repos/"+USER+"/"+REPO+"/issues", nil)
+		owner, repo := repoOfRequest(req)
+		Expect(owner + "/" + repo).Should(Equal(USER + "/" + REPO))
+		req, _ = http.NewRequest(http.MethodGet, "https://api.github.com/search/issues?q="+url.QueryEscape("repo:"+USER+"/"+REPO+" is:issue"), nil)
+		owner, repo = repoOfRequest(req)
+		Expect(owner + "/" + repo).Should(Equal(USER + "/" + REPO))
+		req, _ = http.NewRequest(http.MethodGet, "https://api.github.com/user", nil)
+		owner, _ = repoOfRequest(req)
+		Expect(strings.TrimSpace(owner)).Should(BeEmpty())
+	})
+})
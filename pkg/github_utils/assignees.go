@@ -0,0 +1,64 @@
+package github_utils
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-github/github"
+)
+
+// resolveAssignees splits the wanted assignees into the users that can be
+// assigned to issues in the repo and the ones that can't, e.g. because they
+// aren't collaborators.
+func resolveAssignees(ctx context.Context, client *github.Client, user string, repo string, wanted []string) ([]string, []string, error) {
+	assignable := []string{}
+	var unassignable []string
+	seen := map[string]bool{}
+	for _, login := range wanted {
+		key := strings.ToLower(login)
+		if login == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		ok, resp, err := client.Issues.IsAssignee(ctx, user, repo, login)
+		if err != nil {
+			return nil, nil, classifyError(resp, err, ErrRepoNotFound)
+		}
+		if ok {
+			assignable = append(assignable, login)
+		} else {
+			unassignable = append(unassignable, login)
+		}
+	}
+	return assignable, unassignable, nil
+}
+
+// assigneesMatch reports whether the issue is already assigned to exactly the wanted users.
+func assigneesMatch(current []*github.User, wanted []string) bool {
+	have := make([]string, 0, len(current))
+	for _, user := range current {
+		have = append(have, user.GetLogin())
+	}
+	return namesMatch(have, wanted)
+}
+
+// resolveMilestone returns the number of the repo milestone with the given title.
+func resolveMilestone(ctx context.Context, client *github.Client, user string, repo string, title string) (int, error) {
+	opts := github.MilestoneListOptions{State: "all", ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		milestones, resp, err := client.Issues.ListMilestones(ctx, user, repo, &opts)
+		if err != nil {
+			return 0, classifyError(resp, err, ErrRepoNotFound)
+		}
+		for _, milestone := range milestones {
+			if milestone.GetTitle() == title {
+				return milestone.GetNumber(), nil
+			}
+		}
+		if resp.NextPage == 0 {
+			return 0, fmt.Errorf("%w: %q", ErrMilestoneNotFound, title)
+		}
+		opts.Page = resp.NextPage
+	}
+}
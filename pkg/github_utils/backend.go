@@ -0,0 +1,107 @@
+package github_utils
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// Issues reads and writes the issues of a repo. Its methods behave like the
+// functions of the same name.
+type Issues interface {
+	FetchIssue(repo string, marker Marker, ctx context.Context, client *github.Client) (*github.Issue, error)
+	GetIssue(repo string, number int, ctx context.Context, client *github.Client) (*github.Issue, error)
+	CreateIssue(repo string, issueTitle string, description string, opts IssueOptions, ctx context.Context, client *github.Client) (SyncResult, error)
+	UpdateIssue(repo string, number int, issueTitle string, description string, opts IssueOptions, ctx context.Context, client *github.Client) (SyncResult, error)
+	DeleteIssue(repo string, number int, ctx context.Context, client *github.Client) error
+}
+
+// Backend talks to one of GitHub's APIs, either REST or GraphQL.
+type Backend interface {
+	Issues
+	// ListIssues lists the issues of the repo updated since the given time,
+	// or all of them when it's zero. Pull requests are left out.
+	ListIssues(repo string, since time.Time, ctx context.Context, client *github.Client) ([]*github.Issue, error)
+	// updateFetchedIssue is UpdateIssue for an issue that was already fetched.
+	updateFetchedIssue(repo string, issue *github.Issue, issueTitle string, description string, opts IssueOptions, ctx context.Context, client *github.Client) (SyncResult, error)
+}
+
+// BatchReader is implemented by the backends that fetch many issues in one
+// request.
+type BatchReader interface {
+	// GetIssues fetches the issues with the given numbers. The ones that
+	// don't exist, or are pull requests, are left out.
+	GetIssues(repo string, numbers []int, ctx context.Context, client *github.Client) (map[int]*github.Issue, error)
+}
+
+// The names of the backends, as set on a connection.
+const (
+	APIREST    = "REST"
+	APIGraphQL = "GraphQL"
+)
+
+var (
+	// REST reads and writes issues with the REST API.
+	REST Backend = restBackend{}
+	// GraphQL reads issues with the GraphQL API, fetching many of them in one
+	// query. Title, body and state changes are made with GraphQL mutations,
+	// everything else, like creating issues or changing their labels,
+	// assignees and milestone, goes through the REST API.
+	GraphQL Backend = graphqlBackend{}
+)
+
+// BackendFor returns the backend with the given name, REST when it's empty.
+func BackendFor(api string) Backend {
+	if api == APIGraphQL {
+		return GraphQL
+	}
+	return REST
+}
+
+type restBackend struct{}
+
+func (restBackend) FetchIssue(repo string, marker Marker, ctx context.Context, client *github.Client) (*github.Issue, error) {
+	return FetchIssue(repo, marker, ctx, client)
+}
+
+func (restBackend) GetIssue(repo string, number int, ctx context.Context, client *github.Client) (*github.Issue, error) {
+	return GetIssue(repo, number, ctx, client)
+}
+
+func (restBackend) CreateIssue(repo string, issueTitle string, description string, opts IssueOptions, ctx context.Context, client *github.Client) (SyncResult, error) {
+	return CreateIssue(repo, issueTitle, description, opts, ctx, client)
+}
+
+func (restBackend) UpdateIssue(repo string, number int, issueTitle string, description string, opts IssueOptions, ctx context.Context, client *github.Client) (SyncResult, error) {
+	return UpdateIssue(repo, number, issueTitle, description, opts, ctx, client)
+}
+
+func (restBackend) DeleteIssue(repo string, number int, ctx context.Context, client *github.Client) error {
+	return DeleteIssue(repo, number, ctx, client)
+}
+
+func (restBackend) ListIssues(repo string, since time.Time, ctx context.Context, client *github.Client) ([]*github.Issue, error) {
+	githubAuth := divideUserAndRepo(repo)
+	opts := github.IssueListByRepoOptions{State: "all", Since: since, ListOptions: github.ListOptions{PerPage: 100}}
+	var listed []*github.Issue
+	for {
+		issues, resp, err := client.Issues.ListByRepo(ctx, githubAuth["user"], githubAuth["repo"], &opts)
+		if err != nil {
+			return nil, classifyError(resp, err, ErrRepoNotFound)
+		}
+		for _, issue := range issues {
+			if !issue.IsPullRequest() {
+				listed = append(listed, issue)
+			}
+		}
+		if resp.NextPage == 0 {
+			return listed, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+func (restBackend) updateFetchedIssue(repo string, issue *github.Issue, issueTitle string, description string, opts IssueOptions, ctx context.Context, client *github.Client) (SyncResult, error) {
+	return updateIssue(repo, issue, issueTitle, description, opts, ctx, client)
+}
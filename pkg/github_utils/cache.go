@@ -0,0 +1,173 @@
+package github_utils
+
+import (
+	"bytes"
+	"container/list"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// maxCachedResponses bounds the responses a client keeps around.
+	maxCachedResponses = 1000
+	// maxCachedBytes bounds the size of the bodies a client keeps around.
+	maxCachedBytes = 8 << 20
+	// maxCachedBody is the largest body that's kept, bigger ones, like pages
+	// of a listing, would push most of the others out.
+	maxCachedBody = 512 << 10
+)
+
+// CachedResponses counts the reads GitHub answered with 304 Not Modified,
+// which are served from the cache and don't count against the rate limit.
+var CachedResponses = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "github_issuer_cached_responses_total",
+	Help: "GitHub reads answered with 304 Not Modified and served from the cache.",
+}, []string{"connection"})
+
+type cachedResponse struct {
+	key    string
+	header http.Header
+	body   []byte
+}
+
+// cachingTransport makes reads conditional. It keeps the last response of
+// every read that came with an ETag or a Last-Modified date, sends them back
+// as If-None-Match and If-Modified-Since, and serves the kept response when
+// GitHub answers 304 Not Modified. Reads are always revalidated, so the cache
+// never serves anything GitHub didn't confirm. The least recently used
+// responses are dropped to stay within maxCachedResponses and maxCachedBytes.
+type cachingTransport struct {
+	base       http.RoundTripper
+	connection string
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	// order keeps the most recently used responses first.
+	order *list.List
+	// size is the size of the kept bodies.
+	size int
+}
+
+func newCachingTransport(base http.RoundTripper, connection string) *cachingTransport {
+	return &cachingTransport{base: base, connection: connection, entries: map[string]*list.Element{}, order: list.New()}
+}
+
+func (t *cachingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet || req.Header.Get("Range") != "" {
+		return t.base.RoundTrip(req)
+	}
+	// go-github asks for previews with the Accept header, they're different responses.
+	key := req.URL.String() + " " + req.Header.Get("Accept")
+	cached := t.get(key)
+	if cached != nil {
+		req = req.Clone(req.Context())
+		if etag := cached.header.Get("ETag"); etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		if lastModified := cached.header.Get("Last-Modified"); lastModified != "" {
+			req.Header.Set("If-Modified-Since", lastModified)
+		}
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		CachedResponses.WithLabelValues(t.connection).Inc()
+		return cached.response(req, resp.Header), nil
+	}
+	if resp.StatusCode != http.StatusOK || (resp.Header.Get("ETag") == "" && resp.Header.Get("Last-Modified") == "") {
+		return resp, nil
+	}
+	if resp.ContentLength > maxCachedBody {
+		t.remove(key)
+		return resp, nil
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxCachedBody+1))
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	if len(body) > maxCachedBody {
+		// Too big to keep, the rest is read by the caller.
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		t.remove(key)
+		return resp, nil
+	}
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	t.put(&cachedResponse{key: key, header: resp.Header.Clone(), body: body})
+	return resp, nil
+}
+
+func (t *cachingTransport) get(key string) *cachedResponse {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	element, ok := t.entries[key]
+	if !ok {
+		return nil
+	}
+	t.order.MoveToFront(element)
+	return element.Value.(*cachedResponse)
+}
+
+func (t *cachingTransport) put(cached *cachedResponse) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if element, ok := t.entries[cached.key]; ok {
+		t.size -= len(element.Value.(*cachedResponse).body)
+		element.Value = cached
+		t.order.MoveToFront(element)
+	} else {
+		t.entries[cached.key] = t.order.PushFront(cached)
+	}
+	t.size += len(cached.body)
+	for t.order.Len() > maxCachedResponses || t.size > maxCachedBytes {
+		t.evict(t.order.Back())
+	}
+}
+
+// remove forgets the response kept for the key, which is out of date.
+func (t *cachingTransport) remove(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if element, ok := t.entries[key]; ok {
+		t.evict(element)
+	}
+}
+
+// evict must be called with t.mu held.
+func (t *cachingTransport) evict(element *list.Element) {
+	cached := element.Value.(*cachedResponse)
+	t.order.Remove(element)
+	delete(t.entries, cached.key)
+	t.size -= len(cached.body)
+}
+
+// response rebuilds the kept response, with the headers of the 304 answer,
+// like the current rate limit, replacing the kept ones.
+func (c *cachedResponse) response(req *http.Request, notModified http.Header) *http.Response {
+	header := c.header.Clone()
+	for name, values := range notModified {
+		header[name] = values
+	}
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(c.body)),
+		ContentLength: int64(len(c.body)),
+		Request:       req,
+	}
+}
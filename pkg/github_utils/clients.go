@@ -0,0 +1,169 @@
+package github_utils
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/google/go-github/github"
+)
+
+// defaultHost is public GitHub, every other host is taken for a GitHub
+// Enterprise Server.
+const defaultHost = "github.com"
+
+// RepoHost returns the host of a repo URL such as https://github.com/user/repo.
+func RepoHost(repo string) (string, error) {
+	u, err := url.Parse(repo)
+	if err != nil {
+		return "", err
+	}
+	if u.Host == "" {
+		return "", fmt.Errorf("%q isn't a repo URL", repo)
+	}
+	return strings.ToLower(u.Host), nil
+}
+
+// isDefaultHost reports whether host is public GitHub.
+func isDefaultHost(host string) bool {
+	return host == "" || strings.EqualFold(host, defaultHost)
+}
+
+// hostURLs returns the REST API and upload URLs of a GitHub host.
+func hostURLs(host string) (string, string) {
+	if isDefaultHost(host) {
+		return "https://api.github.com/", "https://uploads.github.com/"
+	}
+	return "https://" + host + "/api/v3/", "https://" + host + "/api/uploads/"
+}
+
+// ClientSet hands out one client per GitHub host, so that GithubIssuers on
+// github.com and on GitHub Enterprise Servers can be served side by side.
+type ClientSet struct {
+	// Credentials are used for github.com when it has none of its own.
+	Credentials Credentials
+	// HostCredentials are the credentials of specific hosts. Clients are
+	// only handed out for the hosts listed here and for github.com, so that
+	// the credentials are never sent to a host a GithubIssuer made up.
+	HostCredentials map[string]Credentials
+	// Options configure the connections of every client. Connection clients
+	// may override them.
+	Options TransportOptions
+	// Transport replaces the transports built from the options when it's set.
+	Transport http.RoundTripper
+
+	mu          sync.Mutex
+	transport   http.RoundTripper
+	clients     map[string]*github.Client
+	connections map[string]connectionClient
+}
+
+// connectionClient is a client built from credentials that may change, along
+// with the version of the credentials it was built from.
+type connectionClient struct {
+	version string
+	client  *github.Client
+}
+
+// ClientFor returns the client for the host of the repo URL. It fails with
+// ErrHostUnknown for hosts without credentials of their own other than
+// github.com.
+func (s *ClientSet) ClientFor(ctx context.Context, repo string) (*github.Client, error) {
+	host, err := RepoHost(repo)
+	if err != nil {
+		return nil, err
+	}
+	creds, ok := s.credentialsFor(host)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrHostUnknown, host)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if client, ok := s.clients[host]; ok {
+		return client, nil
+	}
+	transport, err := s.sharedTransport()
+	if err != nil {
+		return nil, err
+	}
+	client, err := newClient(host, "default", creds, transport, s.Options.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	if s.clients == nil {
+		s.clients = map[string]*github.Client{}
+	}
+	s.clients[host] = client
+	return client, nil
+}
+
+// ConnectionClient returns the client cached under key. A new client is built
+// for the host, credentials and options when none is cached yet or when the
+// cached one was built from another version of them. The options override
+// the ones of the set, and the client gets a connection pool of its own.
+func (s *ClientSet) ConnectionClient(key string, version string, host string, creds Credentials, opts TransportOptions) (*github.Client, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cached, ok := s.connections[key]; ok && cached.version == version {
+		return cached.client, nil
+	}
+	opts = s.Options.Merge(opts)
+	transport, err := s.newTransport(opts)
+	if err != nil {
+		return nil, err
+	}
+	client, err := newClient(host, key, creds, transport, opts.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	if s.connections == nil {
+		s.connections = map[string]connectionClient{}
+	}
+	s.connections[key] = connectionClient{version: version, client: client}
+	return client, nil
+}
+
+// Forget drops the client cached under key.
+func (s *ClientSet) Forget(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.connections, key)
+}
+
+// credentialsFor returns the credentials used for the host, and whether the
+// host is one the credentials may be sent to. The default credentials only
+// ever go to github.com.
+func (s *ClientSet) credentialsFor(host string) (Credentials, bool) {
+	for credsHost, hostCreds := range s.HostCredentials {
+		if strings.EqualFold(credsHost, host) {
+			return hostCreds, true
+		}
+	}
+	if isDefaultHost(host) {
+		return s.Credentials, true
+	}
+	return Credentials{}, false
+}
+
+// sharedTransport returns the transport shared by the clients of the hosts.
+// It must be called with s.mu held.
+func (s *ClientSet) sharedTransport() (http.RoundTripper, error) {
+	if s.transport == nil {
+		transport, err := s.newTransport(s.Options)
+		if err != nil {
+			return nil, err
+		}
+		s.transport = transport
+	}
+	return s.transport, nil
+}
+
+func (s *ClientSet) newTransport(opts TransportOptions) (http.RoundTripper, error) {
+	if s.Transport != nil {
+		return s.Transport, nil
+	}
+	return NewTransport(opts)
+}
@@ -0,0 +1,130 @@
+package github_utils
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+var (
+	ErrIssueNotFound     = errors.New("The issue wasn't found")
+	ErrMilestoneNotFound = errors.New("The milestone wasn't found")
+	ErrRepoNotFound      = errors.New("The repo wasn't found")
+	ErrUnauthorized      = errors.New("The GitHub credentials were rejected")
+	ErrForbidden         = errors.New("The GitHub credentials aren't allowed to do this")
+	ErrRateLimited       = errors.New("The GitHub rate limit was exceeded")
+	ErrValidationFailed  = errors.New("GitHub rejected the request as invalid")
+	ErrIssuesDisabled    = errors.New("Issues are disabled in the repo")
+	ErrIssueWriteDenied  = errors.New("The GitHub credentials can't write issues in the repo")
+	ErrHostUnknown       = errors.New("No GitHub credentials are configured for the host")
+)
+
+// APIError is returned when GitHub answered a request with an error. It
+// matches one of the sentinel errors above with errors.Is and keeps the
+// original go-github error around.
+type APIError struct {
+	// Kind is the sentinel error describing what went wrong.
+	Kind       error
+	StatusCode int
+	// RetryAt is when the request may be retried, set for rate limit errors.
+	RetryAt time.Time
+	Err     error
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%v (status %d): %v", e.Kind, e.StatusCode, e.Err)
+}
+
+func (e *APIError) Is(target error) bool {
+	return target == e.Kind
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// WriteRejected reports whether a write failed with an answer from GitHub, or
+// was held back before reaching it, so that it's known not to have been made.
+// Otherwise, like when the connection broke or GitHub failed with a 5xx, the
+// write may or may not have gone through.
+func WriteRejected(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr)
+}
+
+// classifyError turns an error returned by go-github into an APIError. A 404
+// is reported as notFound, since only the caller knows what was missing.
+// Errors that aren't GitHub's answer, like network errors, are returned as is.
+func classifyError(resp *github.Response, err error, notFound error) error {
+	if err == nil {
+		return nil
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		// Held back by the transport before reaching GitHub.
+		return apiErr
+	}
+	var rateErr *github.RateLimitError
+	if errors.As(err, &rateErr) {
+		return &APIError{Kind: ErrRateLimited, StatusCode: http.StatusForbidden, RetryAt: rateErr.Rate.Reset.Time, Err: err}
+	}
+	var abuseErr *github.AbuseRateLimitError
+	if errors.As(err, &abuseErr) {
+		retryAt := time.Now().Add(time.Minute)
+		if abuseErr.RetryAfter != nil {
+			retryAt = time.Now().Add(*abuseErr.RetryAfter)
+		}
+		return &APIError{Kind: ErrRateLimited, StatusCode: http.StatusForbidden, RetryAt: retryAt, Err: err}
+	}
+	var httpResp *http.Response
+	if resp != nil {
+		httpResp = resp.Response
+	}
+	var errResp *github.ErrorResponse
+	if httpResp == nil && errors.As(err, &errResp) {
+		httpResp = errResp.Response
+	}
+	if httpResp == nil {
+		return err
+	}
+	apiErr = &APIError{StatusCode: httpResp.StatusCode, Err: err}
+	switch httpResp.StatusCode {
+	case http.StatusUnauthorized:
+		apiErr.Kind = ErrUnauthorized
+	case http.StatusForbidden, http.StatusTooManyRequests:
+		if retryAt, limited := rateLimitReset(httpResp); limited || httpResp.StatusCode == http.StatusTooManyRequests {
+			apiErr.Kind = ErrRateLimited
+			apiErr.RetryAt = retryAt
+		} else {
+			apiErr.Kind = ErrForbidden
+		}
+	case http.StatusNotFound, http.StatusGone:
+		apiErr.Kind = notFound
+	case http.StatusUnprocessableEntity:
+		apiErr.Kind = ErrValidationFailed
+	default:
+		return err
+	}
+	return apiErr
+}
+
+// rateLimitReset reads the rate limit headers of a rejected response and
+// reports when it may be retried and whether it was rejected for the rate limit.
+func rateLimitReset(resp *http.Response) (time.Time, bool) {
+	if v := resp.Header.Get("Retry-After"); v != "" {
+		if seconds, err := strconv.Atoi(v); err == nil {
+			return time.Now().Add(time.Duration(seconds) * time.Second), true
+		}
+	}
+	if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		if reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+			return time.Unix(reset, 0), true
+		}
+		return time.Now().Add(time.Minute), true
+	}
+	return time.Time{}, false
+}
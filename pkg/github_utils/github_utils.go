@@ -2,13 +2,72 @@ package github_utils
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/url"
 	"strings"
+	"time"
 
 	"github.com/google/go-github/github"
 	"golang.org/x/oauth2"
 )
 
+// IssueOptions holds the issue attributes that are kept in sync besides the
+// title and the body.
+type IssueOptions struct {
+	// Labels are the labels the issue should carry. A nil slice leaves the
+	// issue's labels untouched, an empty one removes all of them.
+	Labels              []string
+	CreateMissingLabels bool
+	// Assignees are the logins the issue should be assigned to. A nil slice
+	// leaves the assignees untouched, an empty one unassigns everybody.
+	Assignees []string
+	// Milestone is the title of the milestone the issue belongs to. An empty
+	// title leaves the milestone untouched, unless ClearMilestone is set.
+	Milestone string
+	// ClearMilestone takes the issue out of its milestone when Milestone is empty.
+	ClearMilestone bool
+	// State is the wanted state of the issue, "open" or "closed". An empty
+	// state leaves the issue's state untouched.
+	State string
+	// StateReason is sent along when the issue gets closed, "completed" or "not_planned".
+	StateReason string
+	// Marker is embedded in the body to tie the issue to its GithubIssuer.
+	Marker *Marker
+	// BeforeUpdate is called when an update has something to change, right
+	// before it's sent. The update isn't sent when it fails.
+	BeforeUpdate func() error
+}
+
+// issueRequest adds the fields go-github doesn't know about to its IssueRequest.
+type issueRequest struct {
+	github.IssueRequest
+	StateReason *string `json:"state_reason,omitempty"`
+	// ClearMilestone sends a null milestone, which IssueRequest leaves out.
+	ClearMilestone bool `json:"-"`
+}
+
+func (r issueRequest) MarshalJSON() ([]byte, error) {
+	type plain issueRequest
+	if !r.ClearMilestone {
+		return json.Marshal(plain(r))
+	}
+	return json.Marshal(struct {
+		plain
+		Milestone *int `json:"milestone"`
+	}{plain: plain(r)})
+}
+
+// SyncResult reports the parts of the wanted issue that GitHub didn't accept.
+type SyncResult struct {
+	// Issue is the issue as GitHub last returned it.
+	Issue                 *github.Issue
+	RejectedLabels        []string
+	UnassignableAssignees []string
+}
+
 func divideUserAndRepo(repo string) map[string]string {
 	split := strings.Split(repo, "/")
 
@@ -18,69 +77,357 @@ func divideUserAndRepo(repo string) map[string]string {
 	}
 }
 
-func CreateClient(ctx context.Context, token string) (*github.Client, error) {
-	ts := oauth2.StaticTokenSource(
-		&oauth2.Token{AccessToken: token},
-	)
-	tc := oauth2.NewClient(ctx, ts)
+// namesMatch reports whether both lists hold the same names, ignoring order and case.
+func namesMatch(have []string, want []string) bool {
+	if len(have) != len(want) {
+		return false
+	}
+	counts := map[string]int{}
+	for _, name := range have {
+		counts[strings.ToLower(name)]++
+	}
+	for _, name := range want {
+		key := strings.ToLower(name)
+		if counts[key] == 0 {
+			return false
+		}
+		counts[key]--
+	}
+	return true
+}
+
+// editIssue patches the issue with the given number.
+func editIssue(ctx context.Context, client *github.Client, user string, repo string, number int, req *issueRequest) (*github.Issue, error) {
+	u := fmt.Sprintf("repos/%v/%v/issues/%d", user, repo, number)
+	r, err := client.NewRequest("PATCH", u, req)
+	if err != nil {
+		return nil, err
+	}
+	issue := new(github.Issue)
+	resp, err := client.Do(ctx, r, issue)
+	return issue, classifyError(resp, err, ErrIssueNotFound)
+}
+
+// setState fills the state of the request when the issue isn't in the wanted state yet.
+func setState(req *issueRequest, current string, state string, stateReason string) bool {
+	if state == "" || current == state {
+		return false
+	}
+	req.State = &state
+	if state == "closed" && stateReason != "" {
+		req.StateReason = &stateReason
+	}
+	return true
+}
 
-	return github.NewClient(tc), nil
+// Credentials hold either a token or the credentials of a GitHub App, or a
+// pool of them.
+type Credentials struct {
+	Token string
+	// TokenSource is asked for the token on every request when it's set,
+	// instead of using Token.
+	TokenSource oauth2.TokenSource
+	App         *AppCredentials
+	// Pool spreads the requests across several credentials, by the rate
+	// limit budget each of them has left. The other fields are ignored when
+	// it's set.
+	Pool []Credentials
 }
 
-func FetchIssue(repo string, issueTitle string, ctx context.Context, client *github.Client) (*github.Issue, error) {
+// CreateClient builds a client for the GitHub host, either github.com or a
+// GitHub Enterprise Server.
+func CreateClient(ctx context.Context, host string, creds Credentials) (*github.Client, error) {
+	return newClient(host, "default", creds, http.DefaultTransport, 0)
+}
+
+// newClient builds a client for the host. The name of the connection labels
+// its metrics.
+func newClient(host string, connection string, creds Credentials, base http.RoundTripper, timeout time.Duration) (*github.Client, error) {
+	apiURL, uploadURL := hostURLs(host)
+	baseURL, err := url.Parse(apiURL)
+	if err != nil {
+		return nil, err
+	}
+	var transport http.RoundTripper
+	if len(creds.Pool) > 0 {
+		members := make([]*rateLimitTransport, 0, len(creds.Pool))
+		for i, memberCreds := range creds.Pool {
+			auth, err := authTransport(memberCreds, base, baseURL)
+			if err != nil {
+				return nil, err
+			}
+			members = append(members, newRateLimitTransport(auth, fmt.Sprintf("%s#%d", connection, i)))
+		}
+		transport = &poolTransport{members: members}
+	} else {
+		auth, err := authTransport(creds, base, baseURL)
+		if err != nil {
+			return nil, err
+		}
+		transport = newRateLimitTransport(auth, connection)
+	}
+	httpClient := &http.Client{Transport: newCachingTransport(transport, connection), Timeout: timeout}
+	if isDefaultHost(host) {
+		return github.NewClient(httpClient), nil
+	}
+	return github.NewEnterpriseClient(apiURL, uploadURL, httpClient)
+}
+
+// authTransport authenticates the requests with the credentials.
+func authTransport(creds Credentials, base http.RoundTripper, baseURL *url.URL) (http.RoundTripper, error) {
+	switch {
+	case creds.App != nil:
+		return newAppTransport(*creds.App, base, baseURL)
+	case creds.TokenSource != nil:
+		return &oauth2.Transport{Source: creds.TokenSource, Base: base}, nil
+	case creds.Token != "":
+		ts := oauth2.StaticTokenSource(
+			&oauth2.Token{AccessToken: creds.Token},
+		)
+		return &oauth2.Transport{Source: ts, Base: base}, nil
+	default:
+		return base, nil
+	}
+}
+
+// FetchIssue looks up the issue that carries the marker of the same
+// GithubIssuer. It's only used to adopt an issue before its number is known,
+// issues without the marker or with somebody else's are never returned.
+//
+// The Search API is asked first since it's a single call. Its index lags
+// behind and it has its own tight rate limit, so when it finds nothing every
+// open and closed issue of the repo is paged through before giving up.
+func FetchIssue(repo string, marker Marker, ctx context.Context, client *github.Client) (*github.Issue, error) {
 	githubAuth := divideUserAndRepo(repo)
-	opts := github.IssueListByRepoOptions{}
-	issues, _, err := client.Issues.ListByRepo(ctx, githubAuth["user"], githubAuth["repo"], &opts)
+	if issue, err := searchIssue(ctx, client, githubAuth["user"], githubAuth["repo"], marker); err == nil && issue != nil {
+		return issue, nil
+	}
+	opts := github.IssueListByRepoOptions{State: "all", ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		issues, resp, err := client.Issues.ListByRepo(ctx, githubAuth["user"], githubAuth["repo"], &opts)
+		if err != nil {
+			return &github.Issue{}, classifyError(resp, err, ErrRepoNotFound)
+		}
+		for _, issue := range issues {
+			if ownedBy(issue, marker) {
+				return issue, nil
+			}
+		}
+		if resp.NextPage == 0 {
+			return &github.Issue{}, ErrIssueNotFound
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// searchIssue asks the Search API for the issue carrying the marker. It
+// returns nil when the search came back empty.
+func searchIssue(ctx context.Context, client *github.Client, user string, repo string, marker Marker) (*github.Issue, error) {
+	query := fmt.Sprintf("repo:%s/%s is:issue in:body %s %q %q", user, repo, markerKeyword, marker.Namespace, marker.Name)
+	opts := github.SearchOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	result, resp, err := client.Search.Issues(ctx, query, &opts)
 	if err != nil {
+		return nil, classifyError(resp, err, ErrRepoNotFound)
+	}
+	for i := range result.Issues {
+		if ownedBy(&result.Issues[i], marker) {
+			return &result.Issues[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// ownedBy reports whether the issue carries the marker of the same GithubIssuer.
+func ownedBy(issue *github.Issue, marker Marker) bool {
+	if issue.IsPullRequest() {
+		return false
+	}
+	owner, ok := ParseMarker(issue.GetBody())
+	return ok && marker.Owns(owner)
+}
+
+// FetchLegacyIssue looks up an open issue opened by the controller before it
+// marked its issues: one without any marker, with the exact title, authored
+// by the user the client authenticates as. It lets the GithubIssuers of an
+// upgraded controller adopt their issues instead of opening them again, the
+// marker is added by the next update. Credentials without a user of their
+// own, like the installation tokens of a GitHub App, never opened such issues
+// and find nothing.
+func FetchLegacyIssue(repo string, issueTitle string, ctx context.Context, client *github.Client) (*github.Issue, error) {
+	githubAuth := divideUserAndRepo(repo)
+	user, resp, err := client.Users.Get(ctx, "")
+	if err != nil {
+		err = classifyError(resp, err, ErrIssueNotFound)
+		if errors.Is(err, ErrForbidden) || errors.Is(err, ErrIssueNotFound) {
+			return &github.Issue{}, ErrIssueNotFound
+		}
 		return &github.Issue{}, err
 	}
-	for _, issue := range issues {
-		if *issue.Title == issueTitle {
-			return issue, nil
+	opts := github.IssueListByRepoOptions{State: "open", Creator: user.GetLogin(), ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		issues, resp, err := client.Issues.ListByRepo(ctx, githubAuth["user"], githubAuth["repo"], &opts)
+		if err != nil {
+			return &github.Issue{}, classifyError(resp, err, ErrRepoNotFound)
+		}
+		for _, issue := range issues {
+			if _, marked := ParseMarker(issue.GetBody()); !marked && !issue.IsPullRequest() &&
+				issue.GetTitle() == issueTitle && strings.EqualFold(issue.GetUser().GetLogin(), user.GetLogin()) {
+				return issue, nil
+			}
+		}
+		if resp.NextPage == 0 {
+			return &github.Issue{}, ErrIssueNotFound
 		}
+		opts.Page = resp.NextPage
 	}
-	return &github.Issue{}, fmt.Errorf("%v", "The issue wasn't found")
 }
 
-func CreateIssue(repo string, issueTitle string, description string, ctx context.Context, client *github.Client) error {
+// GetIssue fetches the issue with the given number.
+func GetIssue(repo string, number int, ctx context.Context, client *github.Client) (*github.Issue, error) {
 	githubAuth := divideUserAndRepo(repo)
+	issue, resp, err := client.Issues.Get(ctx, githubAuth["user"], githubAuth["repo"], number)
+	if err != nil {
+		return &github.Issue{}, classifyError(resp, err, ErrIssueNotFound)
+	}
+	return issue, nil
+}
+
+func CreateIssue(repo string, issueTitle string, description string, opts IssueOptions, ctx context.Context, client *github.Client) (SyncResult, error) {
+	githubAuth := divideUserAndRepo(repo)
+	result := SyncResult{}
+	body := withMarker(description, opts.Marker)
 	req := github.IssueRequest{
 		Title: &issueTitle,
-		Body:  &description,
+		Body:  &body,
 	}
-	_, _, err := client.Issues.Create(ctx, githubAuth["user"], githubAuth["repo"], &req)
-	return err
+	if opts.Labels != nil {
+		labels, rejected, err := resolveLabels(ctx, client, githubAuth["user"], githubAuth["repo"], opts.Labels, opts.CreateMissingLabels)
+		if err != nil {
+			return result, err
+		}
+		result.RejectedLabels = rejected
+		req.Labels = &labels
+	}
+	if opts.Assignees != nil {
+		assignees, unassignable, err := resolveAssignees(ctx, client, githubAuth["user"], githubAuth["repo"], opts.Assignees)
+		if err != nil {
+			return result, err
+		}
+		result.UnassignableAssignees = unassignable
+		req.Assignees = &assignees
+	}
+	if opts.Milestone != "" {
+		milestone, err := resolveMilestone(ctx, client, githubAuth["user"], githubAuth["repo"], opts.Milestone)
+		if err != nil {
+			return result, err
+		}
+		req.Milestone = &milestone
+	}
+	issue, resp, err := client.Issues.Create(ctx, githubAuth["user"], githubAuth["repo"], &req)
+	if err != nil {
+		return result, classifyError(resp, err, ErrRepoNotFound)
+	}
+	result.Issue = issue
+	stateReq := issueRequest{}
+	if setState(&stateReq, issue.GetState(), opts.State, opts.StateReason) {
+		issue, err = editIssue(ctx, client, githubAuth["user"], githubAuth["repo"], issue.GetNumber(), &stateReq)
+		if err == nil {
+			result.Issue = issue
+		}
+	}
+	return result, err
 }
 
-func UpdateIssue(repo string, issueTitle string, description string, ctx context.Context, client *github.Client) error {
-	githubAuth := divideUserAndRepo(repo)
-	issue, err := FetchIssue(repo, issueTitle, ctx, client)
+// UpdateIssue brings the issue with the given number in line with the wanted
+// title, description and options, renaming it if the title changed.
+func UpdateIssue(repo string, number int, issueTitle string, description string, opts IssueOptions, ctx context.Context, client *github.Client) (SyncResult, error) {
+	issue, err := GetIssue(repo, number, ctx, client)
 	if err != nil {
-		return err
+		return SyncResult{}, err
 	}
-	req := github.IssueRequest{
-		Title: issue.Title,
-		Body:  &description,
+	return updateIssue(repo, issue, issueTitle, description, opts, ctx, client)
+}
+
+// updateIssue brings the issue, as it was last fetched, in line with the
+// wanted title, description and options.
+func updateIssue(repo string, issue *github.Issue, issueTitle string, description string, opts IssueOptions, ctx context.Context, client *github.Client) (SyncResult, error) {
+	req, changed, result, err := planUpdate(repo, issue, issueTitle, description, opts, ctx, client)
+	if err != nil || !changed {
+		return result, err
 	}
-	if description != *issue.Body {
-		_, _, err = client.Issues.Edit(ctx, githubAuth["user"], githubAuth["repo"], *issue.Number, &req)
-		return err
+	githubAuth := divideUserAndRepo(repo)
+	issue, err = editIssue(ctx, client, githubAuth["user"], githubAuth["repo"], issue.GetNumber(), &req)
+	if err == nil {
+		result.Issue = issue
 	}
-	return err
+	return result, err
 }
 
-func DeleteIssue(repo string, issueTitle string, ctx context.Context, client *github.Client) error {
+// planUpdate works out the edit that brings the issue in line with the wanted
+// title, description and options, and reports whether anything needs to change.
+// BeforeUpdate of the options is called once something does.
+func planUpdate(repo string, issue *github.Issue, issueTitle string, description string, opts IssueOptions, ctx context.Context, client *github.Client) (issueRequest, bool, SyncResult, error) {
 	githubAuth := divideUserAndRepo(repo)
-	state := "closed"
-	issue, err := FetchIssue(repo, issueTitle, ctx, client)
-	if err != nil {
-		return err
+	result := SyncResult{Issue: issue}
+	body := withMarker(description, keepWriteKey(opts.Marker, issue.GetBody()))
+	req := issueRequest{IssueRequest: github.IssueRequest{
+		Title: &issueTitle,
+		Body:  &body,
+	}}
+	changed := issueTitle != issue.GetTitle() || body != issue.GetBody()
+	if opts.Labels != nil {
+		labels, rejected, err := resolveLabels(ctx, client, githubAuth["user"], githubAuth["repo"], opts.Labels, opts.CreateMissingLabels)
+		if err != nil {
+			return req, false, result, err
+		}
+		result.RejectedLabels = rejected
+		if !labelsMatch(issue.Labels, labels) {
+			req.Labels = &labels
+			changed = true
+		}
 	}
-	req := github.IssueRequest{
-		Title: issue.Title,
-		Body:  issue.Body,
-		State: &state,
+	if opts.Assignees != nil {
+		assignees, unassignable, err := resolveAssignees(ctx, client, githubAuth["user"], githubAuth["repo"], opts.Assignees)
+		if err != nil {
+			return req, false, result, err
+		}
+		result.UnassignableAssignees = unassignable
+		if !assigneesMatch(issue.Assignees, assignees) {
+			req.Assignees = &assignees
+			changed = true
+		}
+	}
+	if opts.Milestone != "" {
+		milestone, err := resolveMilestone(ctx, client, githubAuth["user"], githubAuth["repo"], opts.Milestone)
+		if err != nil {
+			return req, false, result, err
+		}
+		if issue.Milestone.GetNumber() != milestone {
+			req.Milestone = &milestone
+			changed = true
+		}
+	} else if opts.ClearMilestone && issue.Milestone != nil {
+		req.ClearMilestone = true
+		changed = true
+	}
+	if setState(&req, issue.GetState(), opts.State, opts.StateReason) {
+		changed = true
+	}
+	if changed && opts.BeforeUpdate != nil {
+		if err := opts.BeforeUpdate(); err != nil {
+			return req, false, result, err
+		}
 	}
-	_, _, err = client.Issues.Edit(ctx, githubAuth["user"], githubAuth["repo"], *issue.Number, &req)
+	return req, changed, result, nil
+}
+
+// DeleteIssue closes the issue with the given number.
+func DeleteIssue(repo string, number int, ctx context.Context, client *github.Client) error {
+	githubAuth := divideUserAndRepo(repo)
+	state := "closed"
+	req := issueRequest{IssueRequest: github.IssueRequest{
+		State: &state,
+	}}
+	_, err := editIssue(ctx, client, githubAuth["user"], githubAuth["repo"], number, &req)
 	return err
 }
@@ -2,17 +2,30 @@ package github_utils
 
 import (
 	"context"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/google/go-github/github"
 	"github.com/migueleliasweb/go-github-mock/src/mock"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 const (
 	REGULAR_URL       = "https://github.com/test-user/test-repo"
 	ERROR_URL         = "https://github.com/no-user/no-repo"
+	ENTERPRISE_URL    = "https://ghes.example.com/test-user/test-repo"
 	USER              = "test-user"
 	REPO              = "test-repo"
 	ISSUE             = "test-title"
@@ -20,8 +33,13 @@ const (
 	DESCRIPTION       = "test-body"
 	ERROR_DESCRIPTION = "no-body"
 	NUMBER            = 1
+	MISSING_LABEL     = "needs-triage"
+	OUTSIDER          = "outside-user"
+	MILESTONE         = "v1.0"
 )
 
+var OWNER = Marker{ClusterID: "test-cluster", Namespace: "test-namespace", Name: "test-githubissuer", UID: "test-uid"}
+
 var _ = Describe("Github Utils", func() {
 
 	Context("get the user and the repo from url", func() {
@@ -35,65 +53,735 @@ var _ = Describe("Github Utils", func() {
 		It("Should fetch the issue", func() {
 			c := setupFakeClient("GET")
 			ctx := context.Background()
-			_, err := FetchIssue(REGULAR_URL, ISSUE, ctx, c)
+			_, err := FetchIssue(REGULAR_URL, OWNER, ctx, c)
 			Expect(err).Should(BeNil())
 		})
 		It("Should create the issue", func() {
 			c := setupFakeClient("POST")
 			ctx := context.Background()
-			err := CreateIssue(REGULAR_URL, ISSUE, DESCRIPTION, ctx, c)
+			result, err := CreateIssue(REGULAR_URL, ISSUE, DESCRIPTION, IssueOptions{}, ctx, c)
 			Expect(err).Should(BeNil())
+			Expect(result.Issue.GetNumber()).Should(Equal(NUMBER))
 		})
 		It("Should delete the issue", func() {
 			c := setupFakeClient("PATCH")
 			ctx := context.Background()
-			err := UpdateIssue(REGULAR_URL, ISSUE, DESCRIPTION, ctx, c)
+			_, err := UpdateIssue(REGULAR_URL, NUMBER, ISSUE, DESCRIPTION, IssueOptions{}, ctx, c)
 			Expect(err).Should(BeNil())
 		})
 		It("Should delete the issue", func() {
 			c := setupFakeClient("PATCH")
 			ctx := context.Background()
-			err := DeleteIssue(REGULAR_URL, ISSUE, ctx, c)
+			err := DeleteIssue(REGULAR_URL, NUMBER, ctx, c)
 			Expect(err).Should(BeNil())
 		})
 		It("Should return an error for get", func() {
 			c := setupFakeClient("GET_ERROR")
 			ctx := context.Background()
-			_, err := FetchIssue(ERROR_URL, ERROR_ISSUE, ctx, c)
+			_, err := FetchIssue(ERROR_URL, OWNER, ctx, c)
 			Expect(err).ShouldNot(BeNil())
 		})
 		It("Should return an error for create", func() {
 			c := setupFakeClient("CREATE_ERROR")
 			ctx := context.Background()
-			err := CreateIssue(ERROR_URL, ERROR_ISSUE, ERROR_DESCRIPTION, ctx, c)
+			_, err := CreateIssue(ERROR_URL, ERROR_ISSUE, ERROR_DESCRIPTION, IssueOptions{}, ctx, c)
 			Expect(err).ShouldNot(BeNil())
 		})
 		It("Should return an error for update", func() {
 			c := setupFakeClient("UPDATE_ERROR")
 			ctx := context.Background()
-			err := UpdateIssue(ERROR_URL, ERROR_ISSUE, ERROR_DESCRIPTION, ctx, c)
+			_, err := UpdateIssue(ERROR_URL, NUMBER, ERROR_ISSUE, ERROR_DESCRIPTION, IssueOptions{}, ctx, c)
+			Expect(err).ShouldNot(BeNil())
+		})
+
+	})
+	Context("ownership marker for github_utils", func() {
+		It("Should parse the marker it wrote", func() {
+			body := withMarker(DESCRIPTION, &OWNER)
+			marker, ok := ParseMarker(body)
+			Expect(ok).Should(BeTrue())
+			Expect(marker).Should(Equal(OWNER))
+		})
+		It("Should replace an existing marker", func() {
+			other := Marker{ClusterID: "other-cluster", Namespace: "other", Name: "other"}
+			body := withMarker(withMarker(DESCRIPTION, &other), &OWNER)
+			Expect(body).Should(Equal(DESCRIPTION + "\n\n" + OWNER.String()))
+		})
+		It("Should keep the key of the write that created the issue", func() {
+			created := OWNER
+			created.Write = "create-key"
+			body := withMarker(DESCRIPTION, &created)
+			marker, ok := ParseMarker(body)
+			Expect(ok).Should(BeTrue())
+			Expect(marker.Write).Should(Equal("create-key"))
+			Expect(OWNER.Owns(marker)).Should(BeTrue())
+			issue := &github.Issue{Number: github.Int(NUMBER), Title: github.String(ISSUE), Body: github.String(body)}
+			_, changed, _, err := planUpdate(REGULAR_URL, issue, ISSUE, DESCRIPTION, IssueOptions{Marker: &OWNER}, context.Background(), nil)
+			Expect(err).Should(BeNil())
+			Expect(changed).Should(BeFalse())
+		})
+		It("Should reclaim the issue of a recreated GithubIssuer", func() {
+			recreated := OWNER
+			recreated.UID = "new-uid"
+			Expect(recreated.Owns(OWNER)).Should(BeTrue())
+		})
+		It("Should not adopt issues of others", func() {
+			c := setupFakeClient("STRANGERS")
+			ctx := context.Background()
+			_, err := FetchIssue(REGULAR_URL, OWNER, ctx, c)
+			Expect(err).ShouldNot(BeNil())
+		})
+	})
+	Context("issue lookup for github_utils", func() {
+		It("Should page through closed issues to find the owned one", func() {
+			c := setupFakeClient("PAGES")
+			ctx := context.Background()
+			issue, err := FetchIssue(REGULAR_URL, OWNER, ctx, c)
+			Expect(err).Should(BeNil())
+			Expect(issue.GetNumber()).Should(Equal(NUMBER + 1))
+		})
+		It("Should find the owned issue with the Search API", func() {
+			c := setupFakeClient("SEARCH")
+			ctx := context.Background()
+			issue, err := FetchIssue(REGULAR_URL, OWNER, ctx, c)
+			Expect(err).Should(BeNil())
+			Expect(issue.GetNumber()).Should(Equal(NUMBER))
+		})
+	})
+	Context("upgrades for github_utils", func() {
+		It("Should adopt the unmarked issue the controller opened with the same title", func() {
+			c := setupFakeClient("LEGACY")
+			issue, err := FetchLegacyIssue(REGULAR_URL, ISSUE, context.Background(), c)
+			Expect(err).Should(BeNil())
+			Expect(issue.GetNumber()).Should(Equal(NUMBER + 3))
+			_, err = FetchLegacyIssue(REGULAR_URL, ERROR_ISSUE, context.Background(), setupFakeClient("LEGACY"))
+			Expect(err).Should(MatchError(ErrIssueNotFound))
+		})
+		It("Should find nothing for credentials without a user", func() {
+			c := setupStatusClient(mock.GetUser, http.StatusForbidden, nil)
+			_, err := FetchLegacyIssue(REGULAR_URL, ISSUE, context.Background(), c)
+			Expect(err).Should(MatchError(ErrIssueNotFound))
+		})
+	})
+	Context("typed errors for github_utils", func() {
+		DescribeTable("Should classify the errors of GitHub",
+			func(status int, header http.Header, expected error) {
+				c := setupStatusClient(mock.PostReposIssuesByOwnerByRepo, status, header)
+				ctx := context.Background()
+				_, err := CreateIssue(REGULAR_URL, ISSUE, DESCRIPTION, IssueOptions{}, ctx, c)
+				Expect(errors.Is(err, expected)).Should(BeTrue())
+			},
+			Entry("missing repo", http.StatusNotFound, nil, ErrRepoNotFound),
+			Entry("bad credentials", http.StatusUnauthorized, nil, ErrUnauthorized),
+			Entry("missing permission", http.StatusForbidden, nil, ErrForbidden),
+			Entry("primary rate limit", http.StatusForbidden, http.Header{"X-Ratelimit-Remaining": {"0"}, "X-Ratelimit-Reset": {"1700000000"}}, ErrRateLimited),
+			Entry("secondary rate limit", http.StatusForbidden, http.Header{"Retry-After": {"60"}}, ErrRateLimited),
+			Entry("invalid request", http.StatusUnprocessableEntity, nil, ErrValidationFailed),
+		)
+		It("Should report a missing issue", func() {
+			c := setupStatusClient(mock.GetReposIssuesByOwnerByRepoByIssueNumber, http.StatusNotFound, nil)
+			ctx := context.Background()
+			_, err := GetIssue(REGULAR_URL, NUMBER, ctx, c)
+			Expect(errors.Is(err, ErrIssueNotFound)).Should(BeTrue())
+		})
+		It("Should keep the time to retry a rate limited request at", func() {
+			c := setupStatusClient(mock.PostReposIssuesByOwnerByRepo, http.StatusForbidden, http.Header{"X-Ratelimit-Remaining": {"0"}, "X-Ratelimit-Reset": {"1700000000"}})
+			ctx := context.Background()
+			_, err := CreateIssue(REGULAR_URL, ISSUE, DESCRIPTION, IssueOptions{}, ctx, c)
+			var apiErr *APIError
+			Expect(errors.As(err, &apiErr)).Should(BeTrue())
+			Expect(apiErr.RetryAt.Unix()).Should(Equal(int64(1700000000)))
+		})
+		It("Should tell a rejected write from one that may have gone through", func() {
+			c := setupStatusClient(mock.PostReposIssuesByOwnerByRepo, http.StatusUnprocessableEntity, nil)
+			_, err := CreateIssue(REGULAR_URL, ISSUE, DESCRIPTION, IssueOptions{}, context.Background(), c)
+			Expect(WriteRejected(err)).Should(BeTrue())
+			c = setupStatusClient(mock.PostReposIssuesByOwnerByRepo, http.StatusBadGateway, nil)
+			_, err = CreateIssue(REGULAR_URL, ISSUE, DESCRIPTION, IssueOptions{}, context.Background(), c)
+			Expect(err).ShouldNot(BeNil())
+			Expect(WriteRejected(err)).Should(BeFalse())
+		})
+	})
+	Context("labels for github_utils", func() {
+		It("Should reject labels missing from the repo", func() {
+			c := setupFakeClient("LABELS")
+			ctx := context.Background()
+			result, err := CreateIssue(REGULAR_URL, ISSUE, DESCRIPTION, IssueOptions{Labels: []string{"bug", MISSING_LABEL}}, ctx, c)
+			Expect(err).Should(BeNil())
+			Expect(result.RejectedLabels).Should(Equal([]string{MISSING_LABEL}))
+		})
+		It("Should create labels missing from the repo when allowed", func() {
+			c := setupFakeClient("LABELS")
+			ctx := context.Background()
+			result, err := CreateIssue(REGULAR_URL, ISSUE, DESCRIPTION, IssueOptions{Labels: []string{"bug", MISSING_LABEL}, CreateMissingLabels: true}, ctx, c)
+			Expect(err).Should(BeNil())
+			Expect(result.RejectedLabels).Should(BeEmpty())
+		})
+		It("Should update the labels of the issue", func() {
+			c := setupFakeClient("LABELS")
+			ctx := context.Background()
+			result, err := UpdateIssue(REGULAR_URL, NUMBER, ISSUE, DESCRIPTION, IssueOptions{Labels: []string{"BUG"}}, ctx, c)
+			Expect(err).Should(BeNil())
+			Expect(result.RejectedLabels).Should(BeEmpty())
+		})
+		It("Should compare labels case insensitively", func() {
+			current := []github.Label{{Name: github.String("Bug")}, {Name: github.String("triage")}}
+			Expect(labelsMatch(current, []string{"triage", "bug"})).Should(BeTrue())
+			Expect(labelsMatch(current, []string{"bug"})).Should(BeFalse())
+		})
+	})
+	Context("assignees and milestone for github_utils", func() {
+		It("Should report assignees that can't be assigned", func() {
+			c := setupFakeClient("ASSIGNEES")
+			ctx := context.Background()
+			result, err := CreateIssue(REGULAR_URL, ISSUE, DESCRIPTION, IssueOptions{Assignees: []string{USER, OUTSIDER}, Milestone: MILESTONE}, ctx, c)
+			Expect(err).Should(BeNil())
+			Expect(result.UnassignableAssignees).Should(Equal([]string{OUTSIDER}))
+		})
+		It("Should update the assignees and the milestone of the issue", func() {
+			c := setupFakeClient("ASSIGNEES")
+			ctx := context.Background()
+			result, err := UpdateIssue(REGULAR_URL, NUMBER, ISSUE, DESCRIPTION, IssueOptions{Assignees: []string{USER}, Milestone: MILESTONE}, ctx, c)
+			Expect(err).Should(BeNil())
+			Expect(result.UnassignableAssignees).Should(BeEmpty())
+		})
+		It("Should take the issue out of its milestone when asked to", func() {
+			var body map[string]interface{}
+			mockedHTTPClient := mock.NewMockedHTTPClient(
+				mock.WithRequestMatch(
+					mock.GetReposIssuesByOwnerByRepoByIssueNumber,
+					github.Issue{
+						Title:     github.String(ISSUE),
+						Body:      github.String(DESCRIPTION),
+						Number:    github.Int(NUMBER),
+						Milestone: &github.Milestone{Number: github.Int(3), Title: github.String(MILESTONE)},
+					},
+				),
+				mock.WithRequestMatchHandler(
+					mock.PatchReposIssuesByOwnerByRepoByIssueNumber,
+					http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+						json.NewDecoder(r.Body).Decode(&body)
+						w.Write(mock.MustMarshal(github.Issue{Number: github.Int(NUMBER)}))
+					}),
+				),
+			)
+			_, err := UpdateIssue(REGULAR_URL, NUMBER, ISSUE, DESCRIPTION, IssueOptions{ClearMilestone: true}, context.Background(), github.NewClient(mockedHTTPClient))
+			Expect(err).Should(BeNil())
+			Expect(body).Should(HaveKeyWithValue("milestone", BeNil()))
+		})
+		It("Should call BeforeUpdate only when the update changes something", func() {
+			issue := &github.Issue{Number: github.Int(NUMBER), Title: github.String(ISSUE), Body: github.String(DESCRIPTION), State: github.String("open")}
+			calls := 0
+			opts := IssueOptions{BeforeUpdate: func() error {
+				calls++
+				return errors.New("not recorded")
+			}}
+			_, changed, _, err := planUpdate(REGULAR_URL, issue, ISSUE, DESCRIPTION, opts, context.Background(), nil)
+			Expect(err).Should(BeNil())
+			Expect(changed).Should(BeFalse())
+			Expect(calls).Should(BeZero())
+			edits := 0
+			mockedHTTPClient := mock.NewMockedHTTPClient(
+				mock.WithRequestMatchHandler(
+					mock.PatchReposIssuesByOwnerByRepoByIssueNumber,
+					http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+						edits++
+						w.Write(mock.MustMarshal(github.Issue{Number: github.Int(NUMBER)}))
+					}),
+				),
+			)
+			opts.State = "closed"
+			_, err = updateIssue(REGULAR_URL, issue, ISSUE, DESCRIPTION, opts, context.Background(), github.NewClient(mockedHTTPClient))
+			Expect(err).Should(MatchError("not recorded"))
+			Expect(calls).Should(Equal(1))
+			Expect(edits).Should(BeZero())
+		})
+		It("Should return an error for a missing milestone", func() {
+			c := setupFakeClient("ASSIGNEES")
+			ctx := context.Background()
+			_, err := CreateIssue(REGULAR_URL, ISSUE, DESCRIPTION, IssueOptions{Milestone: "no-milestone"}, ctx, c)
+			Expect(err).ShouldNot(BeNil())
+		})
+	})
+	Context("state and title for github_utils", func() {
+		It("Should close the issue with the state reason", func() {
+			var body map[string]interface{}
+			c := setupStateClient("open", &body)
+			ctx := context.Background()
+			_, err := UpdateIssue(REGULAR_URL, NUMBER, ISSUE, DESCRIPTION, IssueOptions{State: "closed", StateReason: "not_planned"}, ctx, c)
+			Expect(err).Should(BeNil())
+			Expect(body).Should(HaveKeyWithValue("state", "closed"))
+			Expect(body).Should(HaveKeyWithValue("state_reason", "not_planned"))
+		})
+		It("Should reopen the issue", func() {
+			var body map[string]interface{}
+			c := setupStateClient("closed", &body)
+			ctx := context.Background()
+			_, err := UpdateIssue(REGULAR_URL, NUMBER, ISSUE, DESCRIPTION, IssueOptions{State: "open", StateReason: "not_planned"}, ctx, c)
+			Expect(err).Should(BeNil())
+			Expect(body).Should(HaveKeyWithValue("state", "open"))
+			Expect(body).ShouldNot(HaveKey("state_reason"))
+		})
+		It("Should rename the issue when the title changed", func() {
+			var body map[string]interface{}
+			c := setupStateClient("open", &body)
+			ctx := context.Background()
+			_, err := UpdateIssue(REGULAR_URL, NUMBER, ISSUE+"-renamed", DESCRIPTION, IssueOptions{}, ctx, c)
+			Expect(err).Should(BeNil())
+			Expect(body).Should(HaveKeyWithValue("title", ISSUE+"-renamed"))
+		})
+		It("Should leave the state alone when it matches", func() {
+			var body map[string]interface{}
+			c := setupStateClient("closed", &body)
+			ctx := context.Background()
+			_, err := UpdateIssue(REGULAR_URL, NUMBER, ISSUE, DESCRIPTION, IssueOptions{State: "closed"}, ctx, c)
+			Expect(err).Should(BeNil())
+			Expect(body).Should(BeNil())
+		})
+	})
+	Context("enterprise hosts for github_utils", func() {
+		It("Should tell the host of a repo", func() {
+			host, err := RepoHost(ENTERPRISE_URL)
+			Expect(err).Should(BeNil())
+			Expect(host).Should(Equal("ghes.example.com"))
+			_, err = RepoHost("test-user/test-repo")
+			Expect(err).ShouldNot(BeNil())
+		})
+		It("Should build a client per host", func() {
+			clients := &ClientSet{
+				Credentials:     Credentials{Token: "public"},
+				HostCredentials: map[string]Credentials{"GHES.example.com": {Token: "enterprise"}},
+			}
+			ctx := context.Background()
+			public, err := clients.ClientFor(ctx, REGULAR_URL)
+			Expect(err).Should(BeNil())
+			Expect(public.BaseURL.String()).Should(Equal("https://api.github.com/"))
+			enterprise, err := clients.ClientFor(ctx, ENTERPRISE_URL)
+			Expect(err).Should(BeNil())
+			Expect(enterprise.BaseURL.String()).Should(Equal("https://ghes.example.com/api/v3/"))
+			Expect(enterprise.UploadURL.String()).Should(Equal("https://ghes.example.com/api/uploads/"))
+			again, err := clients.ClientFor(ctx, ENTERPRISE_URL+"-2")
+			Expect(err).Should(BeNil())
+			Expect(again).Should(BeIdenticalTo(enterprise))
+		})
+		It("Should only send the default credentials to github.com", func() {
+			clients := &ClientSet{Credentials: Credentials{Token: "public"}}
+			_, err := clients.ClientFor(context.Background(), "https://attacker.example/test-user/test-repo")
+			Expect(err).Should(MatchError(ErrHostUnknown))
+			Expect(clients.CheckCredentials(context.Background(), "attacker.example")).Should(MatchError(ErrHostUnknown))
+			Expect(clients.clients).Should(BeEmpty())
+		})
+		It("Should talk to the enterprise host", func() {
+			var host, auth string
+			transport := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+				host, auth = r.URL.Host, r.Header.Get("Authorization")
+				return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"number": 1}`)), Header: http.Header{}, Request: r}, nil
+			})
+			clients := &ClientSet{HostCredentials: map[string]Credentials{"ghes.example.com": {Token: "enterprise"}}, Transport: transport}
+			ctx := context.Background()
+			c, err := clients.ClientFor(ctx, ENTERPRISE_URL)
+			Expect(err).Should(BeNil())
+			_, err = GetIssue(ENTERPRISE_URL, NUMBER, ctx, c)
+			Expect(err).Should(BeNil())
+			Expect(host).Should(Equal("ghes.example.com"))
+			Expect(auth).Should(Equal("Bearer enterprise"))
+		})
+		It("Should rebuild a connection client when its credentials change", func() {
+			clients := &ClientSet{}
+			first, err := clients.ConnectionClient("team/connection", "1", "github.com", Credentials{Token: "first"}, TransportOptions{})
+			Expect(err).Should(BeNil())
+			same, err := clients.ConnectionClient("team/connection", "1", "github.com", Credentials{Token: "first"}, TransportOptions{})
+			Expect(err).Should(BeNil())
+			Expect(same).Should(BeIdenticalTo(first))
+			changed, err := clients.ConnectionClient("team/connection", "2", "github.com", Credentials{Token: "second"}, TransportOptions{})
+			Expect(err).Should(BeNil())
+			Expect(changed).ShouldNot(BeIdenticalTo(first))
+			clients.Forget("team/connection")
+			again, err := clients.ConnectionClient("team/connection", "2", "github.com", Credentials{Token: "second"}, TransportOptions{})
+			Expect(err).Should(BeNil())
+			Expect(again).ShouldNot(BeIdenticalTo(changed))
+		})
+	})
+	Context("transport options for github_utils", func() {
+		It("Should let connection options override the global ones", func() {
+			global := TransportOptions{ProxyURL: "http://proxy:3128", Timeout: time.Minute, MaxConnsPerHost: 10}
+			merged := global.Merge(TransportOptions{Timeout: time.Second})
+			Expect(merged).Should(Equal(TransportOptions{ProxyURL: "http://proxy:3128", Timeout: time.Second, MaxConnsPerHost: 10}))
+		})
+		It("Should build a transport with the proxy and pool limits", func() {
+			transport, err := NewTransport(TransportOptions{ProxyURL: "http://proxy:3128", MaxIdleConnsPerHost: 5, MaxConnsPerHost: 10})
+			Expect(err).Should(BeNil())
+			req, _ := http.NewRequest("GET", "https://api.github.com/", nil)
+			proxyURL, err := transport.Proxy(req)
+			Expect(err).Should(BeNil())
+			Expect(proxyURL.String()).Should(Equal("http://proxy:3128"))
+			Expect(transport.MaxIdleConnsPerHost).Should(Equal(5))
+			Expect(transport.MaxConnsPerHost).Should(Equal(10))
+		})
+		It("Should reject a CA bundle without certificates", func() {
+			_, err := NewTransport(TransportOptions{CABundle: []byte("not a certificate")})
+			Expect(err).ShouldNot(BeNil())
+		})
+		It("Should trust the CA bundle", func() {
+			server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`{"number": 1}`))
+			}))
+			defer server.Close()
+			caBundle := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+			transport, err := NewTransport(TransportOptions{CABundle: caBundle})
+			Expect(err).Should(BeNil())
+			resp, err := (&http.Client{Transport: transport}).Get(server.URL)
+			Expect(err).Should(BeNil())
+			resp.Body.Close()
+		})
+		It("Should time requests out", func() {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				time.Sleep(200 * time.Millisecond)
+			}))
+			defer server.Close()
+			clients := &ClientSet{Options: TransportOptions{Timeout: 50 * time.Millisecond}}
+			c, err := clients.ConnectionClient("team/connection", "1", "github.com", Credentials{}, TransportOptions{})
+			Expect(err).Should(BeNil())
+			c.BaseURL, _ = url.Parse(server.URL + "/")
+			_, err = GetIssue(REGULAR_URL, NUMBER, context.Background(), c)
 			Expect(err).ShouldNot(BeNil())
 		})
+	})
+	Context("access checks for github_utils", func() {
+		repoClient := func(status int, body string) *github.Client {
+			transport := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+				return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader(body)), Header: http.Header{}, Request: r}, nil
+			})
+			return github.NewClient(&http.Client{Transport: transport})
+		}
+		It("Should accept a repo the credentials can write issues in", func() {
+			c := repoClient(http.StatusOK, `{"full_name": "test-user/test-repo", "has_issues": true, "permissions": {"pull": true, "triage": true}}`)
+			Expect(CheckRepoAccess(REGULAR_URL, context.Background(), c)).Should(Succeed())
+		})
+		It("Should accept a repo without permissions in the answer", func() {
+			c := repoClient(http.StatusOK, `{"full_name": "test-user/test-repo", "has_issues": true}`)
+			Expect(CheckRepoAccess(REGULAR_URL, context.Background(), c)).Should(Succeed())
+		})
+		It("Should refuse a repo with issues disabled", func() {
+			c := repoClient(http.StatusOK, `{"full_name": "test-user/test-repo", "has_issues": false, "permissions": {"push": true}}`)
+			Expect(CheckRepoAccess(REGULAR_URL, context.Background(), c)).Should(MatchError(ErrIssuesDisabled))
+		})
+		It("Should refuse a repo the credentials can only read", func() {
+			c := repoClient(http.StatusOK, `{"full_name": "test-user/test-repo", "has_issues": true, "permissions": {"pull": true}}`)
+			Expect(CheckRepoAccess(REGULAR_URL, context.Background(), c)).Should(MatchError(ErrIssueWriteDenied))
+		})
+		It("Should refuse a missing repo", func() {
+			c := repoClient(http.StatusNotFound, `{"message": "Not Found"}`)
+			Expect(CheckRepoAccess(REGULAR_URL, context.Background(), c)).Should(MatchError(ErrRepoNotFound))
+		})
+		It("Should tell when the token is rejected", func() {
+			transport := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+				Expect(r.URL.Path).Should(Equal("/user"))
+				return &http.Response{StatusCode: http.StatusUnauthorized, Body: io.NopCloser(strings.NewReader(`{"message": "Bad credentials"}`)), Header: http.Header{}, Request: r}, nil
+			})
+			clients := &ClientSet{Credentials: Credentials{Token: "revoked"}, Transport: transport}
+			Expect(clients.CheckCredentials(context.Background(), "github.com")).Should(MatchError(ErrUnauthorized))
+		})
+		It("Should check every member of a pool", func() {
+			var tokens []string
+			transport := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+				Expect(r.URL.Path).Should(Equal("/user"))
+				token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+				tokens = append(tokens, token)
+				if token == "revoked" {
+					return &http.Response{StatusCode: http.StatusUnauthorized, Body: io.NopCloser(strings.NewReader(`{"message": "Bad credentials"}`)), Header: http.Header{}, Request: r}, nil
+				}
+				return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"login": "test-user"}`)), Header: http.Header{}, Request: r}, nil
+			})
+			clients := &ClientSet{Credentials: TokenCredentials("valid,revoked"), Transport: transport}
+			Expect(clients.CheckCredentials(context.Background(), "github.com")).Should(MatchError(ErrUnauthorized))
+			Expect(tokens).Should(Equal([]string{"valid", "revoked"}))
+		})
+		It("Should have nothing to check without credentials", func() {
+			clients := &ClientSet{}
+			Expect(clients.CheckCredentials(context.Background(), "github.com")).Should(Succeed())
+		})
+	})
+	Context("rate limits for github_utils", func() {
+		rateLimitedClient := func(remaining int, status int, header http.Header) (*github.Client, *int) {
+			calls := 0
+			reset := time.Now().Add(time.Hour).Unix()
+			transport := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+				calls++
+				h := http.Header{}
+				h.Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+				h.Set("X-RateLimit-Reset", strconv.FormatInt(reset, 10))
+				h.Set("X-RateLimit-Resource", "core")
+				for k, v := range header {
+					h[k] = v
+				}
+				return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader(`{"number": 1, "state": "open"}`)), Header: h, Request: r}, nil
+			})
+			clients := &ClientSet{Transport: transport}
+			c, err := clients.ConnectionClient("team/rate-limited", "1", "github.com", Credentials{}, TransportOptions{})
+			Expect(err).Should(BeNil())
+			return c, &calls
+		}
+		It("Should hold writes back when only the reserve is left", func() {
+			c, calls := rateLimitedClient(writeReserve, http.StatusOK, nil)
+			ctx := context.Background()
+			_, err := GetIssue(REGULAR_URL, NUMBER, ctx, c)
+			Expect(err).Should(BeNil())
+			_, err = GetIssue(REGULAR_URL, NUMBER, ctx, c)
+			Expect(err).Should(BeNil())
+			err = DeleteIssue(REGULAR_URL, NUMBER, ctx, c)
+			Expect(err).Should(MatchError(ErrRateLimited))
+			var apiErr *APIError
+			Expect(errors.As(err, &apiErr)).Should(BeTrue())
+			Expect(apiErr.RetryAt).Should(BeTemporally(">", time.Now()))
+			Expect(*calls).Should(Equal(2))
+			Expect(testutil.ToFloat64(RateLimitRemaining.WithLabelValues("team/rate-limited", "api.github.com", "core"))).Should(Equal(float64(writeReserve)))
+		})
+		It("Should only count GraphQL mutations as writes", func() {
+			base := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+				return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{}`)), Header: http.Header{}, Request: r}, nil
+			})
+			transport := newRateLimitTransport(base, "team/graphql")
+			transport.budgets["graphql"] = rateLimitBudget{remaining: writeReserve, reset: time.Now().Add(time.Hour)}
+			query, _ := http.NewRequest(http.MethodPost, "https://api.github.com/graphql", strings.NewReader(`{}`))
+			_, err := transport.RoundTrip(query)
+			Expect(err).Should(BeNil())
+			Expect(transport.writes).Should(BeEmpty())
+			mutation := query.WithContext(context.WithValue(context.Background(), graphqlMutationKey{}, true))
+			_, err = transport.RoundTrip(mutation)
+			Expect(err).Should(MatchError(ErrRateLimited))
+		})
+		It("Should hold every request back once the budget is spent", func() {
+			c, calls := rateLimitedClient(0, http.StatusOK, nil)
+			ctx := context.Background()
+			GetIssue(REGULAR_URL, NUMBER, ctx, c)
+			_, err := GetIssue(REGULAR_URL, NUMBER, ctx, c)
+			Expect(err).Should(MatchError(ErrRateLimited))
+			Expect(*calls).Should(Equal(1))
+		})
+		It("Should back off after a secondary rate limit", func() {
+			c, calls := rateLimitedClient(4000, http.StatusForbidden, http.Header{"Retry-After": []string{"30"}})
+			ctx := context.Background()
+			GetIssue(REGULAR_URL, NUMBER, ctx, c)
+			_, err := GetIssue(REGULAR_URL, NUMBER, ctx, c)
+			Expect(err).Should(MatchError(ErrRateLimited))
+			Expect(*calls).Should(Equal(1))
+		})
+		It("Should pace writes below the secondary rate limit", func() {
+			t := newRateLimitTransport(nil, "test")
+			now := time.Now()
+			for i := 0; i < writesPerMinute; i++ {
+				Expect(t.reserve("core", true, now)).Should(Succeed())
+			}
+			Expect(t.reserve("core", true, now)).Should(MatchError(ErrRateLimited))
+			Expect(t.reserve("core", false, now)).Should(Succeed())
+			Expect(t.reserve("core", true, now.Add(time.Minute))).Should(Succeed())
+		})
+		It("Should skip pooled credentials that ran out until they reset", func() {
+			remaining := map[string]int{"Bearer spent": 0, "Bearer fresh": 100, "Bearer later": 0}
+			used := map[string]int{}
+			reset := time.Now().Add(time.Hour).Unix()
+			transport := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+				auth := r.Header.Get("Authorization")
+				used[auth]++
+				h := http.Header{}
+				h.Set("X-RateLimit-Remaining", strconv.Itoa(remaining[auth]))
+				h.Set("X-RateLimit-Reset", strconv.FormatInt(reset, 10))
+				h.Set("X-RateLimit-Resource", "core")
+				return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"number": 1, "state": "open"}`)), Header: h, Request: r}, nil
+			})
+			clients := &ClientSet{Transport: transport}
+			c, err := clients.ConnectionClient("team/pooled", "1", "github.com", TokenCredentials("spent, fresh"), TransportOptions{})
+			Expect(err).Should(BeNil())
+			ctx := context.Background()
+			for i := 0; i < 4; i++ {
+				_, err = GetIssue(REGULAR_URL, NUMBER, ctx, c)
+				Expect(err).Should(BeNil())
+			}
+			Expect(used).Should(Equal(map[string]int{"Bearer spent": 1, "Bearer fresh": 3}))
 
+			c, err = clients.ConnectionClient("team/pooled", "2", "github.com", TokenCredentials("spent\nlater"), TransportOptions{})
+			Expect(err).Should(BeNil())
+			GetIssue(REGULAR_URL, NUMBER, ctx, c)
+			GetIssue(REGULAR_URL, NUMBER, ctx, c)
+			_, err = GetIssue(REGULAR_URL, NUMBER, ctx, c)
+			Expect(err).Should(MatchError(ErrRateLimited))
+			var apiErr *APIError
+			Expect(errors.As(err, &apiErr)).Should(BeTrue())
+			Expect(apiErr.RetryAt).Should(BeTemporally("~", time.Unix(reset, 0), time.Second))
+		})
+		It("Should pool several tokens or installations", func() {
+			Expect(TokenCredentials(" only\n")).Should(Equal(Credentials{Token: "only"}))
+			Expect(TokenCredentials("one,two\nthree").Pool).Should(HaveLen(3))
+			creds, err := AppInstallationCredentials(AppCredentials{AppID: APP_ID}, "")
+			Expect(err).Should(BeNil())
+			Expect(creds.App.InstallationID).Should(BeZero())
+			creds, err = AppInstallationCredentials(AppCredentials{AppID: APP_ID}, "7, 8")
+			Expect(err).Should(BeNil())
+			Expect(creds.Pool).Should(HaveLen(2))
+			Expect(creds.Pool[1].App.InstallationID).Should(Equal(int64(8)))
+			Expect(creds.Pool[0].App.InstallationID).Should(Equal(int64(7)))
+			_, err = AppInstallationCredentials(AppCredentials{AppID: APP_ID}, "7,x")
+			Expect(err).ShouldNot(BeNil())
+		})
+		It("Should tell the rate limit of a request", func() {
+			u, _ := url.Parse("https://ghes.example.com/api/v3/search/issues")
+			Expect(rateLimitResource(u)).Should(Equal("search"))
+			u, _ = url.Parse("https://api.github.com/graphql")
+			Expect(rateLimitResource(u)).Should(Equal("graphql"))
+			u, _ = url.Parse("https://api.github.com/repos/test-user/test-repo/issues")
+			Expect(rateLimitResource(u)).Should(Equal("core"))
+		})
+	})
+	Context("conditional requests for github_utils", func() {
+		It("Should serve 304 answers from the cache", func() {
+			var conditional int
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("If-None-Match") == `"v1"` {
+					conditional++
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+				w.Header().Set("ETag", `"v1"`)
+				w.Write([]byte(`{"number": 1, "title": "test-title"}`))
+			}))
+			defer server.Close()
+			clients := &ClientSet{}
+			c, err := clients.ConnectionClient("team/cached", "1", "github.com", Credentials{}, TransportOptions{})
+			Expect(err).Should(BeNil())
+			c.BaseURL, _ = url.Parse(server.URL + "/")
+			ctx := context.Background()
+			for i := 0; i < 3; i++ {
+				issue, err := GetIssue(REGULAR_URL, NUMBER, ctx, c)
+				Expect(err).Should(BeNil())
+				Expect(issue.GetTitle()).Should(Equal(ISSUE))
+			}
+			Expect(conditional).Should(Equal(2))
+			Expect(testutil.ToFloat64(CachedResponses.WithLabelValues("team/cached"))).Should(Equal(float64(2)))
+		})
+		It("Should forget the least recently used responses", func() {
+			t := newCachingTransport(nil, "test")
+			for i := 0; i <= maxCachedResponses; i++ {
+				t.put(&cachedResponse{key: strconv.Itoa(i)})
+			}
+			Expect(t.get("0")).Should(BeNil())
+			Expect(t.get("1")).ShouldNot(BeNil())
+		})
+		It("Should bound the size of the responses it keeps", func() {
+			t := newCachingTransport(nil, "test")
+			for i := 0; i <= maxCachedBytes/maxCachedBody; i++ {
+				t.put(&cachedResponse{key: strconv.Itoa(i), body: make([]byte, maxCachedBody)})
+			}
+			Expect(t.size).Should(BeNumerically("<=", maxCachedBytes))
+			Expect(t.get("0")).Should(BeNil())
+			Expect(t.get("1")).ShouldNot(BeNil())
+		})
+		It("Should pass big responses through without keeping them", func() {
+			body := strings.Repeat("x", maxCachedBody+1)
+			base := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+				return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body)), Header: http.Header{"Etag": {`"big"`}}, ContentLength: -1, Request: r}, nil
+			})
+			t := newCachingTransport(base, "test")
+			req, _ := http.NewRequest(http.MethodGet, "https://api.github.com/repos/test-user/test-repo/issues", nil)
+			resp, err := t.RoundTrip(req)
+			Expect(err).Should(BeNil())
+			read, _ := io.ReadAll(resp.Body)
+			Expect(string(read)).Should(Equal(body))
+			Expect(t.order.Len()).Should(BeZero())
+		})
+	})
+	Context("token file for github_utils", func() {
+		It("Should reload the token when the file changes", func() {
+			path := filepath.Join(GinkgoT().TempDir(), "token")
+			Expect(os.WriteFile(path, []byte("first\n"), 0600)).Should(Succeed())
+			source, err := NewFileTokenSource(path)
+			Expect(err).Should(BeNil())
+			token, _ := source.Token()
+			Expect(token.AccessToken).Should(Equal("first"))
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go source.Start(ctx)
+			Eventually(func() string {
+				// Rewritten until the watcher is up and catches it.
+				Expect(os.WriteFile(path, []byte("second"), 0600)).Should(Succeed())
+				token, _ := source.Token()
+				return token.AccessToken
+			}).Should(Equal("second"))
+		})
+		It("Should keep the last token when the file is emptied", func() {
+			path := filepath.Join(GinkgoT().TempDir(), "token")
+			Expect(os.WriteFile(path, []byte("first"), 0600)).Should(Succeed())
+			source, err := NewFileTokenSource(path)
+			Expect(err).Should(BeNil())
+			Expect(os.WriteFile(path, []byte(""), 0600)).Should(Succeed())
+			Expect(source.reload()).ShouldNot(Succeed())
+			token, _ := source.Token()
+			Expect(token.AccessToken).Should(Equal("first"))
+		})
 	})
 })
 
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// setupStateClient serves a single issue in the given state and stores the
+// body of the PATCH request sent for it.
+func setupStateClient(state string, body *map[string]interface{}) *github.Client {
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatch(
+			mock.GetReposIssuesByOwnerByRepoByIssueNumber,
+			github.Issue{
+				Title:  github.String(ISSUE),
+				Body:   github.String(DESCRIPTION),
+				Number: github.Int(NUMBER),
+				State:  github.String(state),
+			},
+		),
+		mock.WithRequestMatchHandler(
+			mock.PatchReposIssuesByOwnerByRepoByIssueNumber,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				json.NewDecoder(r.Body).Decode(body)
+				w.Write(mock.MustMarshal(github.Issue{Number: github.Int(NUMBER)}))
+			}),
+		),
+	)
+	return github.NewClient(mockedHTTPClient)
+}
+
+// setupStatusClient answers every request to the endpoint with the given status and headers.
+func setupStatusClient(endpoint mock.EndpointPattern, status int, header http.Header) *github.Client {
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatchHandler(
+			endpoint,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				for key, values := range header {
+					w.Header()[key] = values
+				}
+				w.WriteHeader(status)
+				w.Write([]byte(`{"message":"test error"}`))
+			}),
+		),
+	)
+	return github.NewClient(mockedHTTPClient)
+}
+
 func setupFakeClient(method string) *github.Client {
 	mockedHTTPClient := mock.NewMockedHTTPClient()
 	if method == "PATCH" {
 		mockedHTTPClient = mock.NewMockedHTTPClient(
 			mock.WithRequestMatch(
-				mock.GetReposIssuesByOwnerByRepo,
-				[]github.Issue{
-					{
-						Title:  github.String(ISSUE),
-						Body:   github.String(DESCRIPTION),
-						Number: github.Int(NUMBER),
-						Repository: &github.Repository{
-							Name: github.String(REPO),
-							Owner: &github.User{
-								Name: github.String(USER),
-							},
+				mock.GetReposIssuesByOwnerByRepoByIssueNumber,
+				github.Issue{
+					Title:  github.String(ISSUE),
+					Body:   github.String(DESCRIPTION),
+					Number: github.Int(NUMBER),
+					Repository: &github.Repository{
+						Name: github.String(REPO),
+						Owner: &github.User{
+							Name: github.String(USER),
 						},
 					},
 				},
@@ -120,7 +808,7 @@ func setupFakeClient(method string) *github.Client {
 				[]github.Issue{
 					{
 						Title:  github.String(ISSUE),
-						Body:   github.String(DESCRIPTION),
+						Body:   github.String(DESCRIPTION + "\n\n" + OWNER.String()),
 						Number: github.Int(NUMBER),
 						Repository: &github.Repository{
 							Name: github.String(REPO),
@@ -187,6 +875,195 @@ func setupFakeClient(method string) *github.Client {
 				}),
 			),
 		)
+	} else if method == "STRANGERS" {
+		stranger := Marker{ClusterID: "test-cluster", Namespace: "other-namespace", Name: "test-githubissuer"}
+		mockedHTTPClient = mock.NewMockedHTTPClient(
+			mock.WithRequestMatch(
+				mock.GetReposIssuesByOwnerByRepo,
+				[]github.Issue{
+					{
+						Title:  github.String(ISSUE),
+						Body:   github.String(DESCRIPTION),
+						Number: github.Int(NUMBER),
+					},
+					{
+						Title:  github.String(ISSUE),
+						Body:   github.String(DESCRIPTION + "\n\n" + stranger.String()),
+						Number: github.Int(NUMBER + 1),
+					},
+				},
+			),
+		)
+	} else if method == "PAGES" {
+		mockedHTTPClient = mock.NewMockedHTTPClient(
+			mock.WithRequestMatchPages(
+				mock.GetReposIssuesByOwnerByRepo,
+				[]github.Issue{
+					{
+						Title:  github.String(ISSUE),
+						Body:   github.String(DESCRIPTION),
+						Number: github.Int(NUMBER),
+					},
+				},
+				[]github.Issue{
+					{
+						Title:  github.String(ISSUE),
+						Body:   github.String(DESCRIPTION + "\n\n" + OWNER.String()),
+						Number: github.Int(NUMBER + 1),
+						State:  github.String("closed"),
+					},
+				},
+			),
+		)
+	} else if method == "LEGACY" {
+		mockedHTTPClient = mock.NewMockedHTTPClient(
+			mock.WithRequestMatch(
+				mock.GetUser,
+				github.User{Login: github.String(USER)},
+			),
+			mock.WithRequestMatchHandler(
+				mock.GetReposIssuesByOwnerByRepo,
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if r.URL.Query().Get("creator") != USER || r.URL.Query().Get("state") != "open" {
+						w.Write(mock.MustMarshal([]github.Issue{}))
+						return
+					}
+					w.Write(mock.MustMarshal([]github.Issue{
+						{
+							Title:  github.String(ISSUE),
+							Body:   github.String(DESCRIPTION + "\n\n" + OWNER.String()),
+							Number: github.Int(NUMBER),
+							User:   &github.User{Login: github.String(USER)},
+						},
+						{
+							Title:  github.String(ISSUE),
+							Body:   github.String(DESCRIPTION),
+							Number: github.Int(NUMBER + 1),
+							User:   &github.User{Login: github.String(OUTSIDER)},
+						},
+						{
+							Title:            github.String(ISSUE),
+							Body:             github.String(DESCRIPTION),
+							Number:           github.Int(NUMBER + 2),
+							User:             &github.User{Login: github.String(USER)},
+							PullRequestLinks: &github.PullRequestLinks{URL: github.String("https://api.github.com/pulls/3")},
+						},
+						{
+							Title:  github.String(ISSUE),
+							Body:   github.String(DESCRIPTION),
+							Number: github.Int(NUMBER + 3),
+							User:   &github.User{Login: github.String(USER)},
+						},
+					}))
+				}),
+			),
+		)
+	} else if method == "SEARCH" {
+		mockedHTTPClient = mock.NewMockedHTTPClient(
+			mock.WithRequestMatchHandler(
+				mock.GetSearchIssues,
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if !strings.Contains(r.URL.Query().Get("q"), "repo:"+USER+"/"+REPO) {
+						w.WriteHeader(http.StatusUnprocessableEntity)
+						return
+					}
+					w.Write(mock.MustMarshal(github.IssuesSearchResult{
+						Total: github.Int(1),
+						Issues: []github.Issue{
+							{
+								Title:  github.String(ISSUE),
+								Body:   github.String(DESCRIPTION + "\n\n" + OWNER.String()),
+								Number: github.Int(NUMBER),
+							},
+						},
+					}))
+				}),
+			),
+		)
+	} else if method == "LABELS" {
+		mockedHTTPClient = mock.NewMockedHTTPClient(
+			mock.WithRequestMatch(
+				mock.GetReposIssuesByOwnerByRepoByIssueNumber,
+				github.Issue{
+					Title:  github.String(ISSUE),
+					Body:   github.String(DESCRIPTION),
+					Number: github.Int(NUMBER),
+				},
+			),
+			mock.WithRequestMatch(
+				mock.GetReposLabelsByOwnerByRepo,
+				[]github.Label{
+					{Name: github.String("bug")},
+				},
+			),
+			mock.WithRequestMatch(
+				mock.PostReposLabelsByOwnerByRepo,
+				github.Label{Name: github.String(MISSING_LABEL)},
+			),
+			mock.WithRequestMatch(
+				mock.PostReposIssuesByOwnerByRepo,
+				github.Issue{
+					Title:  github.String(ISSUE),
+					Body:   github.String(DESCRIPTION),
+					Number: github.Int(NUMBER),
+				},
+			),
+			mock.WithRequestMatch(
+				mock.PatchReposIssuesByOwnerByRepoByIssueNumber,
+				github.Issue{
+					Title:  github.String(ISSUE),
+					Body:   github.String(DESCRIPTION),
+					Number: github.Int(NUMBER),
+					Labels: []github.Label{{Name: github.String("bug")}},
+				},
+			),
+		)
+	} else if method == "ASSIGNEES" {
+		mockedHTTPClient = mock.NewMockedHTTPClient(
+			mock.WithRequestMatch(
+				mock.GetReposIssuesByOwnerByRepoByIssueNumber,
+				github.Issue{
+					Title:  github.String(ISSUE),
+					Body:   github.String(DESCRIPTION),
+					Number: github.Int(NUMBER),
+				},
+			),
+			mock.WithRequestMatchHandler(
+				mock.GetReposAssigneesByOwnerByRepoByAssignee,
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if strings.HasSuffix(r.URL.Path, "/"+OUTSIDER) {
+						w.WriteHeader(http.StatusNotFound)
+						w.Write([]byte(`{"message":"Not Found"}`))
+						return
+					}
+					w.WriteHeader(http.StatusNoContent)
+				}),
+			),
+			mock.WithRequestMatchHandler(
+				mock.GetReposMilestonesByOwnerByRepo,
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.Write(mock.MustMarshal([]github.Milestone{
+						{Title: github.String(MILESTONE), Number: github.Int(NUMBER)},
+					}))
+				}),
+			),
+			mock.WithRequestMatch(
+				mock.PostReposIssuesByOwnerByRepo,
+				github.Issue{
+					Title:  github.String(ISSUE),
+					Body:   github.String(DESCRIPTION),
+					Number: github.Int(NUMBER),
+				},
+			),
+			mock.WithRequestMatch(
+				mock.PatchReposIssuesByOwnerByRepoByIssueNumber,
+				github.Issue{
+					Title:  github.String(ISSUE),
+					Body:   github.String(DESCRIPTION),
+					Number: github.Int(NUMBER),
+				},
+			),
+		)
 	}
 	c := github.NewClient(mockedHTTPClient)
 	return c
@@ -0,0 +1,383 @@
+package github_utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// graphqlBatchSize is how many issues are fetched in one query.
+const graphqlBatchSize = 100
+
+// issueFields are the fields of the issues read with GraphQL.
+const issueFields = `
+fragment issueFields on Issue {
+	id
+	number
+	title
+	body
+	state
+	url
+	updatedAt
+	labels(first: 100) { nodes { name } }
+	assignees(first: 100) { nodes { login } }
+	milestone { number title }
+}
+`
+
+const listIssuesQuery = `query($owner: String!, $name: String!, $since: DateTime, $after: String) {
+	repository(owner: $owner, name: $name) {
+		issues(first: 100, after: $after, filterBy: {since: $since}) {
+			nodes { ...issueFields }
+			pageInfo { hasNextPage endCursor }
+		}
+	}
+}
+` + issueFields
+
+const searchIssuesQuery = `query($query: String!) {
+	search(query: $query, type: ISSUE, first: 100) {
+		nodes { ...issueFields }
+	}
+}
+` + issueFields
+
+// graphqlIssue is an issue as GraphQL returns it.
+type graphqlIssue struct {
+	ID        string    `json:"id"`
+	Number    int       `json:"number"`
+	Title     string    `json:"title"`
+	Body      string    `json:"body"`
+	State     string    `json:"state"`
+	URL       string    `json:"url"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	Labels    struct {
+		Nodes []struct {
+			Name string `json:"name"`
+		} `json:"nodes"`
+	} `json:"labels"`
+	Assignees struct {
+		Nodes []struct {
+			Login string `json:"login"`
+		} `json:"nodes"`
+	} `json:"assignees"`
+	Milestone *struct {
+		Number int    `json:"number"`
+		Title  string `json:"title"`
+	} `json:"milestone"`
+}
+
+// issue converts the issue to the go-github one the rest of the package works with.
+func (i *graphqlIssue) issue() *github.Issue {
+	state := strings.ToLower(i.State)
+	updatedAt := i.UpdatedAt
+	issue := &github.Issue{
+		NodeID:    &i.ID,
+		Number:    &i.Number,
+		Title:     &i.Title,
+		Body:      &i.Body,
+		State:     &state,
+		HTMLURL:   &i.URL,
+		UpdatedAt: &updatedAt,
+	}
+	for _, label := range i.Labels.Nodes {
+		issue.Labels = append(issue.Labels, github.Label{Name: github.String(label.Name)})
+	}
+	for _, assignee := range i.Assignees.Nodes {
+		issue.Assignees = append(issue.Assignees, &github.User{Login: github.String(assignee.Login)})
+	}
+	if i.Milestone != nil {
+		issue.Milestone = &github.Milestone{Number: &i.Milestone.Number, Title: &i.Milestone.Title}
+	}
+	return issue
+}
+
+// graphqlError is an error GraphQL reported alongside the data of its answer.
+type graphqlError struct {
+	Type    string        `json:"type"`
+	Path    []interface{} `json:"path"`
+	Message string        `json:"message"`
+}
+
+// graphqlURL returns the GraphQL endpoint next to the REST API at baseURL. On
+// GitHub Enterprise Server that's /api/graphql rather than /api/v3/graphql.
+func graphqlURL(baseURL *url.URL) string {
+	u := *baseURL
+	if strings.HasSuffix(u.Path, "/api/v3/") {
+		u.Path = strings.TrimSuffix(u.Path, "v3/") + "graphql"
+		return u.String()
+	}
+	return u.ResolveReference(&url.URL{Path: "graphql"}).String()
+}
+
+// graphqlRepoKey carries the repo a GraphQL request is about, which its URL
+// doesn't tell, for the GitHub App transport to find the installation by.
+type graphqlRepoKey struct{}
+
+// postGraphQL sends the query about the repo to the GraphQL API of the
+// client's host and decodes the data of the answer into data. The errors
+// GraphQL reported alongside the data are returned for the caller to sort out.
+func postGraphQL(ctx context.Context, client *github.Client, repo string, query string, variables map[string]interface{}, data interface{}) ([]graphqlError, error) {
+	req, err := client.NewRequest(http.MethodPost, graphqlURL(client.BaseURL), map[string]interface{}{"query": query, "variables": variables})
+	if err != nil {
+		return nil, err
+	}
+	ctx = context.WithValue(ctx, graphqlRepoKey{}, repo)
+	if strings.HasPrefix(strings.TrimSpace(query), "mutation") {
+		ctx = context.WithValue(ctx, graphqlMutationKey{}, true)
+	}
+	var answer struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []graphqlError  `json:"errors"`
+	}
+	resp, err := client.Do(ctx, req, &answer)
+	if err != nil {
+		return nil, classifyError(resp, err, ErrRepoNotFound)
+	}
+	if len(answer.Data) > 0 && string(answer.Data) != "null" {
+		if err := json.Unmarshal(answer.Data, data); err != nil {
+			return nil, err
+		}
+	}
+	return answer.Errors, nil
+}
+
+// graphqlFailure turns the errors GraphQL reported into an APIError. A
+// NOT_FOUND error is reported as notFound. Errors GraphQL didn't give a type,
+// like those of a malformed query, are returned as is.
+func graphqlFailure(errs []graphqlError, notFound error) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	var kind error
+	messages := make([]string, 0, len(errs))
+	for _, e := range errs {
+		messages = append(messages, e.Message)
+		if kind != nil {
+			continue
+		}
+		switch e.Type {
+		case "NOT_FOUND":
+			kind = notFound
+		case "FORBIDDEN":
+			kind = ErrForbidden
+		case "RATE_LIMITED":
+			kind = ErrRateLimited
+		case "UNPROCESSABLE":
+			kind = ErrValidationFailed
+		}
+	}
+	err := fmt.Errorf("GraphQL: %s", strings.Join(messages, "; "))
+	if kind == nil {
+		return err
+	}
+	return &APIError{Kind: kind, StatusCode: http.StatusOK, Err: err}
+}
+
+type graphqlBackend struct{}
+
+// GetIssues fetches the issues in batches of graphqlBatchSize, each with a
+// single query that asks the repo for every issue under an alias.
+func (graphqlBackend) GetIssues(repo string, numbers []int, ctx context.Context, client *github.Client) (map[int]*github.Issue, error) {
+	githubAuth := divideUserAndRepo(repo)
+	variables := map[string]interface{}{"owner": githubAuth["user"], "name": githubAuth["repo"]}
+	issues := map[int]*github.Issue{}
+	for start := 0; start < len(numbers); start += graphqlBatchSize {
+		end := start + graphqlBatchSize
+		if end > len(numbers) {
+			end = len(numbers)
+		}
+		var query strings.Builder
+		query.WriteString("query($owner: String!, $name: String!) {\n\trepository(owner: $owner, name: $name) {\n")
+		for _, number := range numbers[start:end] {
+			fmt.Fprintf(&query, "\t\ti%d: issue(number: %d) { ...issueFields }\n", number, number)
+		}
+		query.WriteString("\t}\n}\n" + issueFields)
+		var data struct {
+			Repository map[string]*graphqlIssue `json:"repository"`
+		}
+		errs, err := postGraphQL(ctx, client, repo, query.String(), variables, &data)
+		if err != nil {
+			return nil, err
+		}
+		// Every issue that doesn't exist comes back as null with a NOT_FOUND
+		// error of its own, only a missing repo fails the query.
+		var failed []graphqlError
+		for _, e := range errs {
+			if e.Type != "NOT_FOUND" || len(e.Path) != 2 {
+				failed = append(failed, e)
+			}
+		}
+		if err := graphqlFailure(failed, ErrRepoNotFound); err != nil {
+			return nil, err
+		}
+		for _, issue := range data.Repository {
+			if issue != nil {
+				issues[issue.Number] = issue.issue()
+			}
+		}
+	}
+	return issues, nil
+}
+
+func (b graphqlBackend) GetIssue(repo string, number int, ctx context.Context, client *github.Client) (*github.Issue, error) {
+	issues, err := b.GetIssues(repo, []int{number}, ctx, client)
+	if err != nil {
+		return &github.Issue{}, err
+	}
+	issue, ok := issues[number]
+	if !ok {
+		return &github.Issue{}, &APIError{Kind: ErrIssueNotFound, StatusCode: http.StatusOK, Err: fmt.Errorf("GraphQL: no issue #%d in %s", number, repo)}
+	}
+	return issue, nil
+}
+
+func (graphqlBackend) ListIssues(repo string, since time.Time, ctx context.Context, client *github.Client) ([]*github.Issue, error) {
+	githubAuth := divideUserAndRepo(repo)
+	variables := map[string]interface{}{"owner": githubAuth["user"], "name": githubAuth["repo"], "since": nil, "after": nil}
+	if !since.IsZero() {
+		variables["since"] = since.UTC().Format(time.RFC3339)
+	}
+	var listed []*github.Issue
+	for {
+		var data struct {
+			Repository struct {
+				Issues struct {
+					Nodes    []*graphqlIssue `json:"nodes"`
+					PageInfo struct {
+						HasNextPage bool   `json:"hasNextPage"`
+						EndCursor   string `json:"endCursor"`
+					} `json:"pageInfo"`
+				} `json:"issues"`
+			} `json:"repository"`
+		}
+		errs, err := postGraphQL(ctx, client, repo, listIssuesQuery, variables, &data)
+		if err != nil {
+			return nil, err
+		}
+		if err := graphqlFailure(errs, ErrRepoNotFound); err != nil {
+			return nil, err
+		}
+		for _, issue := range data.Repository.Issues.Nodes {
+			listed = append(listed, issue.issue())
+		}
+		if !data.Repository.Issues.PageInfo.HasNextPage {
+			return listed, nil
+		}
+		variables["after"] = data.Repository.Issues.PageInfo.EndCursor
+	}
+}
+
+// FetchIssue asks the search for the issue carrying the marker first, and
+// pages through the issues of the repo when it finds nothing, like the REST
+// FetchIssue.
+func (b graphqlBackend) FetchIssue(repo string, marker Marker, ctx context.Context, client *github.Client) (*github.Issue, error) {
+	githubAuth := divideUserAndRepo(repo)
+	query := fmt.Sprintf("repo:%s/%s is:issue in:body %s %q %q", githubAuth["user"], githubAuth["repo"], markerKeyword, marker.Namespace, marker.Name)
+	var data struct {
+		Search struct {
+			Nodes []*graphqlIssue `json:"nodes"`
+		} `json:"search"`
+	}
+	if errs, err := postGraphQL(ctx, client, repo, searchIssuesQuery, map[string]interface{}{"query": query}, &data); err == nil && len(errs) == 0 {
+		for _, node := range data.Search.Nodes {
+			if issue := node.issue(); node.Number != 0 && ownedBy(issue, marker) {
+				return issue, nil
+			}
+		}
+	}
+	issues, err := b.ListIssues(repo, time.Time{}, ctx, client)
+	if err != nil {
+		return &github.Issue{}, err
+	}
+	for _, issue := range issues {
+		if ownedBy(issue, marker) {
+			return issue, nil
+		}
+	}
+	return &github.Issue{}, ErrIssueNotFound
+}
+
+func (graphqlBackend) CreateIssue(repo string, issueTitle string, description string, opts IssueOptions, ctx context.Context, client *github.Client) (SyncResult, error) {
+	return CreateIssue(repo, issueTitle, description, opts, ctx, client)
+}
+
+func (b graphqlBackend) UpdateIssue(repo string, number int, issueTitle string, description string, opts IssueOptions, ctx context.Context, client *github.Client) (SyncResult, error) {
+	issue, err := b.GetIssue(repo, number, ctx, client)
+	if err != nil {
+		return SyncResult{}, err
+	}
+	return b.updateFetchedIssue(repo, issue, issueTitle, description, opts, ctx, client)
+}
+
+// updateFetchedIssue edits the title and body with the updateIssue mutation,
+// and closes or reopens the issue with the closeIssue and reopenIssue ones,
+// all in one request. Labels, assignees and milestones are set by name
+// through REST, since GraphQL would need their IDs.
+func (graphqlBackend) updateFetchedIssue(repo string, issue *github.Issue, issueTitle string, description string, opts IssueOptions, ctx context.Context, client *github.Client) (SyncResult, error) {
+	req, changed, result, err := planUpdate(repo, issue, issueTitle, description, opts, ctx, client)
+	if err != nil || !changed {
+		return result, err
+	}
+	if req.Labels != nil || req.Assignees != nil || req.Milestone != nil || req.ClearMilestone || issue.GetNodeID() == "" {
+		githubAuth := divideUserAndRepo(repo)
+		issue, err = editIssue(ctx, client, githubAuth["user"], githubAuth["repo"], issue.GetNumber(), &req)
+		if err == nil {
+			result.Issue = issue
+		}
+		return result, err
+	}
+	declarations := []string{"$id: ID!"}
+	var fields []string
+	variables := map[string]interface{}{"id": issue.GetNodeID()}
+	if req.GetTitle() != issue.GetTitle() || req.GetBody() != issue.GetBody() {
+		declarations = append(declarations, "$title: String!", "$body: String!")
+		fields = append(fields, "edit: updateIssue(input: {id: $id, title: $title, body: $body}) { issue { ...issueFields } }")
+		variables["title"], variables["body"] = req.GetTitle(), req.GetBody()
+	}
+	switch req.GetState() {
+	case "closed":
+		if req.StateReason != nil {
+			declarations = append(declarations, "$stateReason: IssueClosedStateReason")
+			fields = append(fields, "state: closeIssue(input: {issueId: $id, stateReason: $stateReason}) { issue { ...issueFields } }")
+			variables["stateReason"] = strings.ToUpper(*req.StateReason)
+		} else {
+			fields = append(fields, "state: closeIssue(input: {issueId: $id}) { issue { ...issueFields } }")
+		}
+	case "open":
+		fields = append(fields, "state: reopenIssue(input: {issueId: $id}) { issue { ...issueFields } }")
+	}
+	mutation := fmt.Sprintf("mutation(%s) {\n\t%s\n}\n%s", strings.Join(declarations, ", "), strings.Join(fields, "\n\t"), issueFields)
+	type payload struct {
+		Issue *graphqlIssue `json:"issue"`
+	}
+	var data struct {
+		Edit  *payload `json:"edit"`
+		State *payload `json:"state"`
+	}
+	errs, err := postGraphQL(ctx, client, repo, mutation, variables, &data)
+	if err == nil {
+		err = graphqlFailure(errs, ErrIssueNotFound)
+	}
+	if err != nil {
+		return result, err
+	}
+	// The mutations run in order, so the last one returns the issue as it ended up.
+	for _, p := range []*payload{data.State, data.Edit} {
+		if p != nil && p.Issue != nil {
+			result.Issue = p.Issue.issue()
+			break
+		}
+	}
+	return result, nil
+}
+
+func (graphqlBackend) DeleteIssue(repo string, number int, ctx context.Context, client *github.Client) error {
+	return DeleteIssue(repo, number, ctx, client)
+}
@@ -0,0 +1,198 @@
+package github_utils
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/github"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// graphqlServer stands in for the GraphQL API of GitHub. It knows the queries
+// and mutations the GraphQL backend sends, not GraphQL itself.
+type graphqlServer struct {
+	mu       sync.Mutex
+	issues   map[int]map[string]interface{}
+	queries  []string
+	noRepo   bool
+	searched int
+}
+
+var aliasedIssue = regexp.MustCompile(`i(\d+): issue\(number: (\d+)\)`)
+
+func (s *graphqlServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	Expect(r.URL.Path).Should(Equal("/graphql"))
+	var req struct {
+		Query     string                 `json:"query"`
+		Variables map[string]interface{} `json:"variables"`
+	}
+	Expect(json.NewDecoder(r.Body).Decode(&req)).Should(Succeed())
+	s.queries = append(s.queries, req.Query)
+	data := map[string]interface{}{}
+	var errs []map[string]interface{}
+	switch {
+	case s.noRepo:
+		data["repository"] = nil
+		errs = append(errs, map[string]interface{}{"type": "NOT_FOUND", "path": []string{"repository"}, "message": "Could not resolve to a Repository"})
+	case strings.HasPrefix(req.Query, "mutation"):
+		id := req.Variables["id"].(string)
+		var issue map[string]interface{}
+		for _, known := range s.issues {
+			if known["id"] == id {
+				issue = known
+			}
+		}
+		if strings.Contains(req.Query, "updateIssue(") {
+			issue["title"], issue["body"] = req.Variables["title"], req.Variables["body"]
+			data["edit"] = map[string]interface{}{"issue": copyIssue(issue)}
+		}
+		if strings.Contains(req.Query, "closeIssue(") {
+			issue["state"] = "CLOSED"
+			data["state"] = map[string]interface{}{"issue": copyIssue(issue)}
+		}
+	case strings.Contains(req.Query, "search("):
+		s.searched++
+		data["search"] = map[string]interface{}{"nodes": []interface{}{}}
+	case strings.Contains(req.Query, "issues(first: 100"):
+		nodes := []interface{}{}
+		for _, issue := range s.issues {
+			nodes = append(nodes, issue)
+		}
+		data["repository"] = map[string]interface{}{"issues": map[string]interface{}{
+			"nodes":    nodes,
+			"pageInfo": map[string]interface{}{"hasNextPage": false, "endCursor": ""},
+		}}
+	default:
+		repository := map[string]interface{}{}
+		for _, match := range aliasedIssue.FindAllStringSubmatch(req.Query, -1) {
+			number, _ := strconv.Atoi(match[2])
+			if issue, ok := s.issues[number]; ok {
+				repository["i"+match[1]] = issue
+			} else {
+				repository["i"+match[1]] = nil
+				errs = append(errs, map[string]interface{}{"type": "NOT_FOUND", "path": []string{"repository", "i" + match[1]}, "message": "Could not resolve to an Issue"})
+			}
+		}
+		data["repository"] = repository
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"data": data, "errors": errs})
+}
+
+func copyIssue(issue map[string]interface{}) map[string]interface{} {
+	copied := map[string]interface{}{}
+	for k, v := range issue {
+		copied[k] = v
+	}
+	return copied
+}
+
+var _ = Describe("GraphQL backend", func() {
+	var (
+		stub   *graphqlServer
+		server *httptest.Server
+	)
+	graphqlIssueWith := func(number int, marker Marker) map[string]interface{} {
+		return map[string]interface{}{
+			"id":        "I_" + strconv.Itoa(number),
+			"number":    number,
+			"title":     ISSUE,
+			"body":      withMarker(DESCRIPTION, &marker),
+			"state":     "OPEN",
+			"url":       "https://github.com/" + USER + "/" + REPO + "/issues/" + strconv.Itoa(number),
+			"updatedAt": time.Now().Add(-time.Hour).UTC().Format(time.RFC3339),
+			"labels":    map[string]interface{}{"nodes": []interface{}{map[string]interface{}{"name": "bug"}}},
+			"assignees": map[string]interface{}{"nodes": []interface{}{}},
+			"milestone": nil,
+		}
+	}
+
+	BeforeEach(func() {
+		other := Marker{ClusterID: OWNER.ClusterID, Namespace: OWNER.Namespace, Name: "other-githubissuer"}
+		stub = &graphqlServer{issues: map[int]map[string]interface{}{1: graphqlIssueWith(1, OWNER), 2: graphqlIssueWith(2, other)}}
+		server = httptest.NewServer(stub)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	newClient := func() *github.Client {
+		c := github.NewClient(nil)
+		c.BaseURL, _ = url.Parse(server.URL + "/")
+		return c
+	}
+
+	It("Should fetch many issues in one query", func() {
+		issues, err := GraphQL.(BatchReader).GetIssues(REGULAR_URL, []int{1, 2, 99}, context.Background(), newClient())
+		Expect(err).Should(BeNil())
+		Expect(stub.queries).Should(HaveLen(1))
+		Expect(issues).Should(HaveLen(2))
+		Expect(issues[1].GetState()).Should(Equal("open"))
+		Expect(issues[1].GetNodeID()).Should(Equal("I_1"))
+		Expect(issues[1].Labels[0].GetName()).Should(Equal("bug"))
+		Expect(issues[2].GetNumber()).Should(Equal(2))
+	})
+	It("Should report a missing issue", func() {
+		_, err := GraphQL.GetIssue(REGULAR_URL, 99, context.Background(), newClient())
+		Expect(err).Should(MatchError(ErrIssueNotFound))
+	})
+	It("Should report a missing repo", func() {
+		stub.noRepo = true
+		_, err := GraphQL.GetIssue(REGULAR_URL, 1, context.Background(), newClient())
+		Expect(err).Should(MatchError(ErrRepoNotFound))
+	})
+	It("Should list the issues when the search finds nothing", func() {
+		issue, err := GraphQL.FetchIssue(REGULAR_URL, OWNER, context.Background(), newClient())
+		Expect(err).Should(BeNil())
+		Expect(issue.GetNumber()).Should(Equal(1))
+		Expect(stub.searched).Should(Equal(1))
+		Expect(stub.queries).Should(HaveLen(2))
+	})
+	It("Should edit and close an issue in one request", func() {
+		result, err := GraphQL.UpdateIssue(REGULAR_URL, 1, "new-title", DESCRIPTION, IssueOptions{Marker: &OWNER, State: "closed", StateReason: "not_planned"}, context.Background(), newClient())
+		Expect(err).Should(BeNil())
+		Expect(result.Issue.GetTitle()).Should(Equal("new-title"))
+		Expect(result.Issue.GetState()).Should(Equal("closed"))
+		Expect(stub.queries).Should(HaveLen(2))
+		Expect(stub.queries[1]).Should(ContainSubstring("updateIssue("))
+		Expect(stub.queries[1]).Should(ContainSubstring("closeIssue("))
+	})
+	It("Should leave an issue in sync alone", func() {
+		_, err := GraphQL.UpdateIssue(REGULAR_URL, 1, ISSUE, DESCRIPTION, IssueOptions{Marker: &OWNER}, context.Background(), newClient())
+		Expect(err).Should(BeNil())
+		Expect(stub.queries).Should(HaveLen(1))
+	})
+	It("Should refresh the tracked issues of an index in one query", func() {
+		index := (&IssueIndex{}).Using(GraphQL)
+		c := newClient()
+		ctx := context.Background()
+		_, err := index.FetchIssue(REGULAR_URL, OWNER, ctx, c)
+		Expect(err).Should(BeNil())
+		for _, number := range []int{1, 2} {
+			_, err = index.GetIssue(REGULAR_URL, number, ctx, c)
+			Expect(err).Should(BeNil())
+		}
+		stub.queries = nil
+		_, err = index.GetIssue(REGULAR_URL, 1, ctx, c)
+		Expect(err).Should(BeNil())
+		Expect(stub.queries).Should(HaveLen(1))
+		Expect(aliasedIssue.FindAllString(stub.queries[0], -1)).Should(HaveLen(2))
+	})
+	It("Should find the GraphQL API of a host", func() {
+		u, _ := url.Parse("https://ghes.example.com/api/v3/")
+		Expect(graphqlURL(u)).Should(Equal("https://ghes.example.com/api/graphql"))
+		u, _ = url.Parse("https://api.github.com/")
+		Expect(graphqlURL(u)).Should(Equal("https://api.github.com/graphql"))
+	})
+})
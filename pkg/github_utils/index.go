@@ -0,0 +1,294 @@
+package github_utils
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+const (
+	// indexClockDrift is how far the listing of the issues updated since the
+	// last listing reaches back, to allow for clock drift between us and GitHub.
+	indexClockDrift = time.Minute
+	// indexIdleTimeout is how long the listing of a repo nobody asked about is kept.
+	indexIdleTimeout = time.Hour
+)
+
+// IssueIndex keeps the issues of the repos the controller works on in memory,
+// so that every GithubIssuer of a repo is served from one listing of it
+// instead of each asking GitHub for its issue. A repo is listed in full on
+// first use. After that, every RefreshInterval, backends that fetch many
+// issues in one request fetch the issues that were asked for by number, the
+// others list the issues updated since the last listing. The issues written
+// through the index replace the listed ones right away. Only the issues that
+// carry a marker or were asked for by number are kept.
+type IssueIndex struct {
+	// RefreshInterval is how long the issues of a repo are used before they're
+	// refreshed.
+	RefreshInterval time.Duration
+
+	mu    sync.Mutex
+	repos map[indexKey]*repoIssues
+}
+
+// indexKey tells the listings apart by client, since other credentials may
+// see other issues.
+type indexKey struct {
+	client *github.Client
+	repo   string
+}
+
+// repoIssues is the listing of one repo. mu is held while the repo is listed,
+// so that concurrent reconciles wait for a single listing.
+type repoIssues struct {
+	mu sync.Mutex
+	// listedAt is when the last listing started, zero until the repo was
+	// listed in full.
+	listedAt time.Time
+	// refreshedAt is when the last listing or fetch of the tracked issues started.
+	refreshedAt time.Time
+	usedAt      time.Time
+	issues      map[int]*github.Issue
+	// owners maps the markers, without their UID, to the issues carrying them.
+	owners map[Marker]int
+	// tracked are the numbers of the issues that were asked for by number.
+	tracked map[int]bool
+}
+
+// Using returns the issues of the index, read and written with the backend.
+// A nil index returns the backend itself.
+func (x *IssueIndex) Using(backend Backend) Issues {
+	if x == nil {
+		return backend
+	}
+	return &indexedIssues{index: x, backend: backend}
+}
+
+type indexedIssues struct {
+	index   *IssueIndex
+	backend Backend
+}
+
+// FetchIssue looks up the issue that carries the marker in the listing of the
+// repo. The issues updated since the last listing are listed before giving up.
+func (i *indexedIssues) FetchIssue(repo string, marker Marker, ctx context.Context, client *github.Client) (*github.Issue, error) {
+	issues := i.index.repo(repo, client)
+	issues.mu.Lock()
+	defer issues.mu.Unlock()
+	listedAt := issues.listedAt
+	if err := i.refresh(issues, repo, false, ctx, client); err != nil {
+		return &github.Issue{}, err
+	}
+	issue := issues.owned(marker)
+	if issue == nil && issues.listedAt == listedAt {
+		// Nothing was listed just now, the issue may have been opened since.
+		if err := i.refresh(issues, repo, true, ctx, client); err != nil {
+			return &github.Issue{}, err
+		}
+		issue = issues.owned(marker)
+	}
+	if issue == nil {
+		return &github.Issue{}, ErrIssueNotFound
+	}
+	return issue, nil
+}
+
+// GetIssue returns the issue with the given number from the listing of the
+// repo. The issues the listing can't vouch for are fetched from GitHub.
+func (i *indexedIssues) GetIssue(repo string, number int, ctx context.Context, client *github.Client) (*github.Issue, error) {
+	issues := i.index.repo(repo, client)
+	issues.mu.Lock()
+	defer issues.mu.Unlock()
+	if err := i.refresh(issues, repo, false, ctx, client); err != nil {
+		return &github.Issue{}, err
+	}
+	// Backends that batch only keep the tracked issues current.
+	_, batches := i.backend.(BatchReader)
+	if issue, ok := issues.issues[number]; ok && (issues.tracked[number] || !batches) {
+		issues.tracked[number] = true
+		return issue, nil
+	}
+	issue, err := i.backend.GetIssue(repo, number, ctx, client)
+	if err != nil {
+		return issue, err
+	}
+	issues.tracked[number] = true
+	issues.put(issue)
+	return issue, nil
+}
+
+func (i *indexedIssues) CreateIssue(repo string, issueTitle string, description string, opts IssueOptions, ctx context.Context, client *github.Client) (SyncResult, error) {
+	result, err := i.backend.CreateIssue(repo, issueTitle, description, opts, ctx, client)
+	if result.Issue != nil {
+		i.index.store(repo, client, result.Issue)
+	}
+	return result, err
+}
+
+// UpdateIssue brings the issue in line with the wanted title, description and
+// options, comparing them with the issue in the listing of the repo. The
+// issue is dropped from the listing when the update fails, since it's no
+// longer known how it looks.
+func (i *indexedIssues) UpdateIssue(repo string, number int, issueTitle string, description string, opts IssueOptions, ctx context.Context, client *github.Client) (SyncResult, error) {
+	issue, err := i.GetIssue(repo, number, ctx, client)
+	if err != nil {
+		return SyncResult{}, err
+	}
+	result, err := i.backend.updateFetchedIssue(repo, issue, issueTitle, description, opts, ctx, client)
+	if err != nil {
+		i.index.forget(repo, client, number)
+	} else {
+		i.index.store(repo, client, result.Issue)
+	}
+	return result, err
+}
+
+func (i *indexedIssues) DeleteIssue(repo string, number int, ctx context.Context, client *github.Client) error {
+	err := i.backend.DeleteIssue(repo, number, ctx, client)
+	i.index.forget(repo, client, number)
+	return err
+}
+
+// refresh lists the repo in full the first time, and the issues updated since
+// the last listing when forced. Otherwise, once the issues are older than
+// the refresh interval, a backend that batches fetches the tracked issues and
+// any other lists the issues updated since the last listing. It must be
+// called with issues.mu held.
+func (i *indexedIssues) refresh(issues *repoIssues, repo string, force bool, ctx context.Context, client *github.Client) error {
+	now := time.Now()
+	if !issues.listedAt.IsZero() && !force && now.Sub(issues.refreshedAt) < i.index.RefreshInterval {
+		return nil
+	}
+	if batch, ok := i.backend.(BatchReader); ok && !issues.listedAt.IsZero() && !force {
+		numbers := make([]int, 0, len(issues.tracked))
+		for number := range issues.tracked {
+			numbers = append(numbers, number)
+		}
+		sort.Ints(numbers)
+		fetched, err := batch.GetIssues(repo, numbers, ctx, client)
+		if err != nil {
+			return err
+		}
+		for _, number := range numbers {
+			if issue, ok := fetched[number]; ok {
+				issues.put(issue)
+			} else {
+				issues.remove(number)
+			}
+		}
+		issues.refreshedAt = now
+		return nil
+	}
+	var since time.Time
+	if !issues.listedAt.IsZero() {
+		since = issues.listedAt.Add(-indexClockDrift)
+	}
+	listed, err := i.backend.ListIssues(repo, since, ctx, client)
+	if err != nil {
+		return err
+	}
+	for _, issue := range listed {
+		issues.put(issue)
+	}
+	issues.listedAt, issues.refreshedAt = now, now
+	return nil
+}
+
+// repo returns the listing of the repo, dropping the ones nobody asked about
+// for a while.
+func (x *IssueIndex) repo(repo string, client *github.Client) *repoIssues {
+	key := indexKey{client: client, repo: strings.ToLower(repo)}
+	now := time.Now()
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	for cachedKey, cached := range x.repos {
+		if cachedKey != key && now.Sub(cached.usedAt) > indexIdleTimeout {
+			delete(x.repos, cachedKey)
+		}
+	}
+	if x.repos == nil {
+		x.repos = map[indexKey]*repoIssues{}
+	}
+	issues, ok := x.repos[key]
+	if !ok {
+		issues = &repoIssues{issues: map[int]*github.Issue{}, owners: map[Marker]int{}, tracked: map[int]bool{}}
+		x.repos[key] = issues
+	}
+	issues.usedAt = now
+	return issues
+}
+
+func (x *IssueIndex) store(repo string, client *github.Client, issue *github.Issue) {
+	issues := x.repo(repo, client)
+	issues.mu.Lock()
+	defer issues.mu.Unlock()
+	issues.tracked[issue.GetNumber()] = true
+	issues.put(issue)
+}
+
+func (x *IssueIndex) forget(repo string, client *github.Client, number int) {
+	issues := x.repo(repo, client)
+	issues.mu.Lock()
+	defer issues.mu.Unlock()
+	issues.remove(number)
+}
+
+// owned returns the issue carrying the marker of the same GithubIssuer, if any.
+func (r *repoIssues) owned(marker Marker) *github.Issue {
+	marker.UID = ""
+	number, ok := r.owners[marker]
+	if !ok {
+		return nil
+	}
+	return r.issues[number]
+}
+
+// put adds the issue to the listing, unless the listing holds a newer version
+// of it. Only the issues that carry a marker or were asked for by number are
+// kept, no GithubIssuer is served any other. Pull requests are left out.
+func (r *repoIssues) put(issue *github.Issue) {
+	if issue.IsPullRequest() {
+		return
+	}
+	number := issue.GetNumber()
+	if known, ok := r.issues[number]; ok && known.GetUpdatedAt().After(issue.GetUpdatedAt()) {
+		return
+	}
+	r.drop(number)
+	owner, marked := ParseMarker(issue.GetBody())
+	if !marked && !r.tracked[number] {
+		return
+	}
+	r.issues[number] = issue
+	if marked {
+		owner.UID = ""
+		// The oldest issue wins when several carry the same marker.
+		if known, ok := r.owners[owner]; !ok || number < known {
+			r.owners[owner] = number
+		}
+	}
+}
+
+// remove drops the issue from the listing and stops tracking it.
+func (r *repoIssues) remove(number int) {
+	delete(r.tracked, number)
+	r.drop(number)
+}
+
+func (r *repoIssues) drop(number int) {
+	known, ok := r.issues[number]
+	if !ok {
+		return
+	}
+	delete(r.issues, number)
+	if owner, ok := ParseMarker(known.GetBody()); ok {
+		owner.UID = ""
+		if r.owners[owner] == number {
+			delete(r.owners, owner)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package github_utils
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/github"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Issue index", func() {
+	var (
+		server *httptest.Server
+		mu     sync.Mutex
+		issues map[int]*github.Issue
+		lists  []url.Values
+		gets   int
+	)
+	other := Marker{ClusterID: OWNER.ClusterID, Namespace: OWNER.Namespace, Name: "other-githubissuer"}
+	missing := Marker{ClusterID: OWNER.ClusterID, Namespace: OWNER.Namespace, Name: "missing-githubissuer"}
+	issueWith := func(number int, marker Marker) *github.Issue {
+		body := withMarker(DESCRIPTION, &marker)
+		state := "open"
+		updatedAt := time.Now().Add(-time.Hour)
+		return &github.Issue{Number: &number, Title: github.String(ISSUE), Body: &body, State: &state, UpdatedAt: &updatedAt}
+	}
+
+	BeforeEach(func() {
+		issues = map[int]*github.Issue{1: issueWith(1, OWNER), 2: issueWith(2, other)}
+		lists = nil
+		gets = 0
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+			prefix := "/repos/" + USER + "/" + REPO + "/issues"
+			switch {
+			case r.URL.Path == prefix && r.Method == http.MethodGet:
+				lists = append(lists, r.URL.Query())
+				listed := []*github.Issue{}
+				for _, issue := range issues {
+					listed = append(listed, issue)
+				}
+				json.NewEncoder(w).Encode(listed)
+			case r.URL.Path == prefix && r.Method == http.MethodPost:
+				var req github.IssueRequest
+				json.NewDecoder(r.Body).Decode(&req)
+				number := len(issues) + 1
+				now := time.Now()
+				issues[number] = &github.Issue{Number: &number, Title: req.Title, Body: req.Body, State: github.String("open"), UpdatedAt: &now}
+				w.WriteHeader(http.StatusCreated)
+				json.NewEncoder(w).Encode(issues[number])
+			case strings.HasPrefix(r.URL.Path, prefix+"/"):
+				number, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, prefix+"/"))
+				issue, ok := issues[number]
+				if !ok {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				if r.Method == http.MethodGet {
+					gets++
+				} else {
+					var req github.IssueRequest
+					json.NewDecoder(r.Body).Decode(&req)
+					now := time.Now()
+					issue = &github.Issue{Number: &number, Title: req.Title, Body: req.Body, State: issue.State, UpdatedAt: &now}
+					issues[number] = issue
+				}
+				json.NewEncoder(w).Encode(issue)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	newClient := func() *github.Client {
+		c := github.NewClient(nil)
+		c.BaseURL, _ = url.Parse(server.URL + "/")
+		return c
+	}
+
+	It("Should serve every GithubIssuer of a repo from one listing", func() {
+		index := (&IssueIndex{RefreshInterval: time.Hour}).Using(REST)
+		c := newClient()
+		ctx := context.Background()
+		issue, err := index.FetchIssue(REGULAR_URL, OWNER, ctx, c)
+		Expect(err).Should(BeNil())
+		Expect(issue.GetNumber()).Should(Equal(1))
+		issue, err = index.FetchIssue(REGULAR_URL, other, ctx, c)
+		Expect(err).Should(BeNil())
+		Expect(issue.GetNumber()).Should(Equal(2))
+		issue, err = index.GetIssue(REGULAR_URL, 1, ctx, c)
+		Expect(err).Should(BeNil())
+		Expect(issue.GetTitle()).Should(Equal(ISSUE))
+		Expect(lists).Should(HaveLen(1))
+		Expect(lists[0].Get("since")).Should(BeEmpty())
+		Expect(gets).Should(BeZero())
+	})
+	It("Should keep the issues it writes", func() {
+		index := (&IssueIndex{RefreshInterval: time.Hour}).Using(REST)
+		c := newClient()
+		ctx := context.Background()
+		result, err := index.UpdateIssue(REGULAR_URL, 1, "new-title", DESCRIPTION, IssueOptions{Marker: &OWNER}, ctx, c)
+		Expect(err).Should(BeNil())
+		Expect(result.Issue.GetTitle()).Should(Equal("new-title"))
+		issue, err := index.GetIssue(REGULAR_URL, 1, ctx, c)
+		Expect(err).Should(BeNil())
+		Expect(issue.GetTitle()).Should(Equal("new-title"))
+		result, err = index.CreateIssue(REGULAR_URL, ISSUE, DESCRIPTION, IssueOptions{Marker: &missing}, ctx, c)
+		Expect(err).Should(BeNil())
+		issue, err = index.FetchIssue(REGULAR_URL, missing, ctx, c)
+		Expect(err).Should(BeNil())
+		Expect(issue.GetNumber()).Should(Equal(result.Issue.GetNumber()))
+		Expect(lists).Should(HaveLen(1))
+		Expect(gets).Should(BeZero())
+	})
+	It("Should list the issues updated since the last listing before giving up", func() {
+		index := (&IssueIndex{RefreshInterval: time.Hour}).Using(REST)
+		c := newClient()
+		ctx := context.Background()
+		_, err := index.FetchIssue(REGULAR_URL, OWNER, ctx, c)
+		Expect(err).Should(BeNil())
+		_, err = index.FetchIssue(REGULAR_URL, missing, ctx, c)
+		Expect(err).Should(MatchError(ErrIssueNotFound))
+		Expect(lists).Should(HaveLen(2))
+		Expect(lists[1].Get("since")).ShouldNot(BeEmpty())
+	})
+	It("Should fetch the issues it doesn't know", func() {
+		index := (&IssueIndex{RefreshInterval: time.Hour}).Using(REST)
+		c := newClient()
+		ctx := context.Background()
+		_, err := index.FetchIssue(REGULAR_URL, OWNER, ctx, c)
+		Expect(err).Should(BeNil())
+		Expect(index.DeleteIssue(REGULAR_URL, 1, ctx, c)).Should(Succeed())
+		_, err = index.GetIssue(REGULAR_URL, 1, ctx, c)
+		Expect(err).Should(BeNil())
+		Expect(gets).Should(Equal(1))
+		_, err = index.GetIssue(REGULAR_URL, 1, ctx, c)
+		Expect(err).Should(BeNil())
+		Expect(gets).Should(Equal(1))
+	})
+	It("Should only keep the issues a GithubIssuer may be served", func() {
+		unmarked := "no marker"
+		issues[3] = &github.Issue{Number: github.Int(3), Title: github.String(ISSUE), Body: &unmarked, State: github.String("open"), UpdatedAt: issues[1].UpdatedAt}
+		index := (&IssueIndex{RefreshInterval: time.Hour}).Using(REST)
+		c := newClient()
+		ctx := context.Background()
+		_, err := index.FetchIssue(REGULAR_URL, OWNER, ctx, c)
+		Expect(err).Should(BeNil())
+		listing := index.(*indexedIssues).index.repo(REGULAR_URL, c)
+		Expect(listing.issues).Should(HaveLen(2))
+		issue, err := index.GetIssue(REGULAR_URL, 3, ctx, c)
+		Expect(err).Should(BeNil())
+		Expect(issue.GetBody()).Should(Equal(unmarked))
+		Expect(listing.issues).Should(HaveKey(3))
+		Expect(gets).Should(Equal(1))
+	})
+	It("Should pass through to GitHub when it's nil", func() {
+		index := (*IssueIndex)(nil).Using(REST)
+		_, err := index.GetIssue(REGULAR_URL, 1, context.Background(), newClient())
+		Expect(err).Should(BeNil())
+		Expect(gets).Should(Equal(1))
+	})
+})
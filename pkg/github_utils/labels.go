@@ -0,0 +1,81 @@
+package github_utils
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/google/go-github/github"
+)
+
+const defaultLabelColor = "ededed"
+
+// listRepoLabels returns every label of the repo keyed by its lowercased name,
+// since GitHub treats label names case-insensitively.
+func listRepoLabels(ctx context.Context, client *github.Client, user string, repo string) (map[string]string, error) {
+	labels := map[string]string{}
+	opts := github.ListOptions{PerPage: 100}
+	for {
+		page, resp, err := client.Issues.ListLabels(ctx, user, repo, &opts)
+		if err != nil {
+			return nil, classifyError(resp, err, ErrRepoNotFound)
+		}
+		for _, label := range page {
+			labels[strings.ToLower(label.GetName())] = label.GetName()
+		}
+		if resp.NextPage == 0 {
+			return labels, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// resolveLabels splits the wanted labels into the ones that exist in the repo
+// (creating missing ones when allowed) and the ones that were rejected.
+func resolveLabels(ctx context.Context, client *github.Client, user string, repo string, wanted []string, createMissing bool) ([]string, []string, error) {
+	if len(wanted) == 0 {
+		return []string{}, nil, nil
+	}
+	existing, err := listRepoLabels(ctx, client, user, repo)
+	if err != nil {
+		return nil, nil, err
+	}
+	accepted := []string{}
+	var rejected []string
+	seen := map[string]bool{}
+	for _, name := range wanted {
+		key := strings.ToLower(name)
+		if name == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		if canonical, ok := existing[key]; ok {
+			accepted = append(accepted, canonical)
+			continue
+		}
+		if !createMissing {
+			rejected = append(rejected, name)
+			continue
+		}
+		label := github.Label{Name: github.String(name), Color: github.String(defaultLabelColor)}
+		if _, resp, err := client.Issues.CreateLabel(ctx, user, repo, &label); err != nil {
+			err = classifyError(resp, err, ErrRepoNotFound)
+			if errors.Is(err, ErrValidationFailed) {
+				rejected = append(rejected, name)
+				continue
+			}
+			return nil, nil, err
+		}
+		accepted = append(accepted, name)
+	}
+	return accepted, rejected, nil
+}
+
+// labelsMatch reports whether the issue already carries exactly the wanted labels.
+func labelsMatch(current []github.Label, wanted []string) bool {
+	have := make([]string, 0, len(current))
+	for _, label := range current {
+		have = append(have, label.GetName())
+	}
+	return namesMatch(have, wanted)
+}
@@ -0,0 +1,80 @@
+package github_utils
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+const (
+	markerKeyword = "github-issuer-owner"
+	markerPrefix  = "<!-- " + markerKeyword + ": "
+)
+
+var markerRegexp = regexp.MustCompile(`<!-- ` + markerKeyword + `: (\{[^\n]*?\}) -->`)
+
+// Marker identifies the GithubIssuer that owns an issue. It's embedded in the
+// issue body as a hidden HTML comment so the issue can be found again.
+type Marker struct {
+	ClusterID string `json:"cluster"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	UID       string `json:"uid"`
+	// Write is the key of the write that created the issue, so that a create
+	// whose outcome was lost can tell its issue apart.
+	Write string `json:"write,omitempty"`
+}
+
+func (m Marker) String() string {
+	data, _ := json.Marshal(m)
+	return markerPrefix + string(data) + " -->"
+}
+
+// Owns reports whether the other marker was written for the same GithubIssuer.
+// The UID isn't compared, so a recreated GithubIssuer reclaims its issue, nor
+// is the key of the write.
+func (m Marker) Owns(other Marker) bool {
+	return m.ClusterID == other.ClusterID && m.Namespace == other.Namespace && m.Name == other.Name
+}
+
+// ParseMarker returns the marker embedded in the issue body, if any.
+func ParseMarker(body string) (Marker, bool) {
+	match := markerRegexp.FindStringSubmatch(body)
+	if match == nil {
+		return Marker{}, false
+	}
+	var marker Marker
+	if err := json.Unmarshal([]byte(match[1]), &marker); err != nil {
+		return Marker{}, false
+	}
+	return marker, true
+}
+
+// keepWriteKey returns the marker with the key of the write that created the
+// issue, when the marker the issue carries is the same GithubIssuer's. The
+// key stays in the body for as long as the issue lives.
+func keepWriteKey(marker *Marker, body string) *Marker {
+	if marker == nil || marker.Write != "" {
+		return marker
+	}
+	existing, ok := ParseMarker(body)
+	if !ok || !marker.Owns(existing) {
+		return marker
+	}
+	kept := *marker
+	kept.Write = existing.Write
+	return &kept
+}
+
+// withMarker appends the marker to the description, replacing any marker the
+// description already carries.
+func withMarker(description string, marker *Marker) string {
+	if marker == nil {
+		return description
+	}
+	description = strings.TrimRight(markerRegexp.ReplaceAllString(description, ""), "\n")
+	if description == "" {
+		return marker.String()
+	}
+	return description + "\n\n" + marker.String()
+}
@@ -0,0 +1,56 @@
+package github_utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// splitList splits a list separated by commas or whitespace.
+func splitList(value string) []string {
+	return strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	})
+}
+
+// TokenCredentials returns the credentials of the tokens in value, separated
+// by commas or whitespace. Several tokens make a pool.
+func TokenCredentials(value string) Credentials {
+	tokens := splitList(value)
+	switch len(tokens) {
+	case 0:
+		return Credentials{}
+	case 1:
+		return Credentials{Token: tokens[0]}
+	}
+	pool := make([]Credentials, 0, len(tokens))
+	for _, token := range tokens {
+		pool = append(pool, Credentials{Token: token})
+	}
+	return Credentials{Pool: pool}
+}
+
+// AppInstallationCredentials returns the credentials of the installations of
+// the app in installationIDs, separated by commas or whitespace. Several
+// installations make a pool, without any the installation is looked up for
+// every repo owner.
+func AppInstallationCredentials(app AppCredentials, installationIDs string) (Credentials, error) {
+	ids := splitList(installationIDs)
+	if len(ids) == 0 {
+		return Credentials{App: &app}, nil
+	}
+	pool := make([]Credentials, 0, len(ids))
+	for _, id := range ids {
+		installation := app
+		var err error
+		if installation.InstallationID, err = strconv.ParseInt(id, 10, 64); err != nil {
+			return Credentials{}, fmt.Errorf("invalid installation ID %q: %w", id, err)
+		}
+		pool = append(pool, Credentials{App: &installation})
+	}
+	if len(pool) == 1 {
+		return pool[0], nil
+	}
+	return Credentials{Pool: pool}, nil
+}
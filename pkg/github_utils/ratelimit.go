@@ -0,0 +1,252 @@
+package github_utils
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// writeReserve is the part of the primary rate limit kept for reads.
+	// Writes are held back once no more than this is left.
+	writeReserve = 50
+	// writesPerMinute keeps writes below GitHub's secondary rate limit of 80
+	// content creating requests per minute.
+	writesPerMinute = 60
+	// secondaryBackoff is how long requests are held back after a secondary
+	// rate limit that didn't say when to retry.
+	secondaryBackoff = time.Minute
+	// unknownBudget is assumed for credentials GitHub hasn't told the budget of yet.
+	unknownBudget = 5000
+)
+
+// RateLimitRemaining is the number of requests left in the current rate
+// limit window of each client.
+var RateLimitRemaining = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "github_issuer_rate_limit_remaining",
+	Help: "Requests left in the current GitHub rate limit window.",
+}, []string{"connection", "host", "resource"})
+
+// errRateLimitReserved is wrapped by the errors of requests that were held
+// back before reaching GitHub.
+var errRateLimitReserved = errors.New("request held back to stay within the GitHub rate limit")
+
+type rateLimitBudget struct {
+	remaining int
+	reset     time.Time
+}
+
+// rateLimitTransport keeps track of the rate limit budget of one set of
+// credentials from the X-RateLimit headers of the responses and holds
+// requests back before GitHub would reject them. Writes stop while only the
+// reserve of the primary budget is left, or when they'd exceed the secondary
+// limit, so that reads keep working. Held back requests fail with a rate
+// limit APIError telling when to retry.
+type rateLimitTransport struct {
+	base       http.RoundTripper
+	connection string
+
+	mu           sync.Mutex
+	budgets      map[string]rateLimitBudget
+	blockedUntil time.Time
+	writes       []time.Time
+}
+
+func newRateLimitTransport(base http.RoundTripper, connection string) *rateLimitTransport {
+	return &rateLimitTransport{base: base, connection: connection, budgets: map[string]rateLimitBudget{}}
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resource := rateLimitResource(req.URL)
+	if err := t.reserve(resource, isWrite(req), time.Now()); err != nil {
+		return nil, err
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	t.observe(req.URL.Host, resource, resp, time.Now())
+	if resource == "graphql" && resp.StatusCode < http.StatusMultipleChoices {
+		// go-github files the budget of every answer under the core rate
+		// limit, it mustn't hold REST requests back for the GraphQL one.
+		for _, header := range []string{"X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset"} {
+			resp.Header.Del(header)
+		}
+	}
+	return resp, nil
+}
+
+// reserve fails when the request would exceed what's left of the budget.
+func (t *rateLimitTransport) reserve(resource string, write bool, now time.Time) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, err := t.headroom(resource, write, now); err != nil {
+		return err
+	}
+	if write {
+		t.writes = append(t.writes, now)
+	}
+	return nil
+}
+
+// headroom tells how many requests are left for the resource, or fails when
+// the request would exceed the budget. Credentials GitHub hasn't told the
+// budget of yet are assumed to have a full one. It must be called with t.mu held.
+func (t *rateLimitTransport) headroom(resource string, write bool, now time.Time) (int, error) {
+	if now.Before(t.blockedUntil) {
+		return 0, heldBack(t.blockedUntil, "secondary rate limit")
+	}
+	remaining := unknownBudget
+	if budget, ok := t.budgets[resource]; ok && now.Before(budget.reset) {
+		if budget.remaining <= 0 || (write && budget.remaining <= writeReserve) {
+			return 0, heldBack(budget.reset, resource+" rate limit")
+		}
+		remaining = budget.remaining
+	}
+	if !write {
+		return remaining, nil
+	}
+	recent := t.writes[:0]
+	for _, at := range t.writes {
+		if now.Sub(at) < time.Minute {
+			recent = append(recent, at)
+		}
+	}
+	t.writes = recent
+	if len(t.writes) >= writesPerMinute {
+		return 0, heldBack(t.writes[0].Add(time.Minute), "writes per minute")
+	}
+	return remaining, nil
+}
+
+// poolTransport sends every request with the member that has the most of
+// its rate limit budget left. Members that ran out are skipped until their
+// budget resets.
+type poolTransport struct {
+	members []*rateLimitTransport
+}
+
+func (t *poolTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resource := rateLimitResource(req.URL)
+	write := isWrite(req)
+	now := time.Now()
+	var best *rateLimitTransport
+	bestRemaining := -1
+	var retryAt time.Time
+	for _, member := range t.members {
+		member.mu.Lock()
+		remaining, err := member.headroom(resource, write, now)
+		member.mu.Unlock()
+		var apiErr *APIError
+		if errors.As(err, &apiErr) {
+			if retryAt.IsZero() || apiErr.RetryAt.Before(retryAt) {
+				retryAt = apiErr.RetryAt
+			}
+			continue
+		}
+		if remaining > bestRemaining {
+			best, bestRemaining = member, remaining
+		}
+	}
+	if best == nil {
+		return nil, heldBack(retryAt, "every pooled credential")
+	}
+	resp, err := best.RoundTrip(req)
+	if err == nil && resp.Header.Get("X-RateLimit-Remaining") != "" {
+		if r := resp.Header.Get("X-RateLimit-Resource"); r != "" {
+			resource = r
+		}
+		t.summarize(resp.Header, resource, time.Now())
+	}
+	return resp, err
+}
+
+// summarize rewrites the rate limit headers of a response to the budget of
+// the whole pool, so that go-github doesn't refuse requests once the member
+// that answered runs out.
+func (t *poolTransport) summarize(header http.Header, resource string, now time.Time) {
+	total := 0
+	var reset time.Time
+	for _, member := range t.members {
+		member.mu.Lock()
+		budget, ok := member.budgets[resource]
+		member.mu.Unlock()
+		if !ok || !now.Before(budget.reset) {
+			total += unknownBudget
+			continue
+		}
+		total += budget.remaining
+		if reset.IsZero() || budget.reset.Before(reset) {
+			reset = budget.reset
+		}
+	}
+	header.Set("X-RateLimit-Remaining", strconv.Itoa(total))
+	if !reset.IsZero() {
+		header.Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+	}
+}
+
+// observe records the budget left after a response.
+func (t *rateLimitTransport) observe(host string, resource string, resp *http.Response, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if r := resp.Header.Get("X-RateLimit-Resource"); r != "" {
+		resource = r
+	}
+	remaining, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining"))
+	if err == nil {
+		reset, _ := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+		t.budgets[resource] = rateLimitBudget{remaining: remaining, reset: time.Unix(reset, 0)}
+		RateLimitRemaining.WithLabelValues(t.connection, host, resource).Set(float64(remaining))
+	}
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return
+	}
+	if retryAfter, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		t.blockedUntil = now.Add(time.Duration(retryAfter) * time.Second)
+	} else if resp.StatusCode == http.StatusTooManyRequests {
+		t.blockedUntil = now.Add(secondaryBackoff)
+	}
+}
+
+func heldBack(retryAt time.Time, limit string) error {
+	return &APIError{
+		Kind:       ErrRateLimited,
+		StatusCode: http.StatusTooManyRequests,
+		RetryAt:    retryAt,
+		Err:        fmt.Errorf("%w: %s", errRateLimitReserved, limit),
+	}
+}
+
+// graphqlMutationKey marks the context of the GraphQL requests that are mutations.
+type graphqlMutationKey struct{}
+
+// isWrite tells whether the request changes anything. Every GraphQL request
+// is a POST, only the mutations postGraphQL marked as such are writes.
+func isWrite(req *http.Request) bool {
+	if rateLimitResource(req.URL) == "graphql" {
+		mutation, _ := req.Context().Value(graphqlMutationKey{}).(bool)
+		return mutation
+	}
+	return req.Method != http.MethodGet && req.Method != http.MethodHead && req.Method != http.MethodOptions
+}
+
+// rateLimitResource tells which rate limit a request counts against.
+func rateLimitResource(u *url.URL) string {
+	path := strings.Trim(u.Path, "/")
+	switch {
+	case strings.HasSuffix(path, "graphql"):
+		return "graphql"
+	case strings.HasPrefix(path, "search/") || strings.Contains(path, "/search/"):
+		return "search"
+	default:
+		return "core"
+	}
+}
@@ -0,0 +1,89 @@
+package github_utils
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/go-logr/logr"
+	"golang.org/x/oauth2"
+)
+
+// FileTokenSource serves the token stored in a file, such as a key of a
+// mounted Secret, and reloads it whenever the file changes. Every request
+// asks for the token anew, so a rotated token is picked up by the next
+// request while the ones in flight finish with the old token.
+type FileTokenSource struct {
+	path string
+
+	mu    sync.RWMutex
+	token string
+}
+
+// NewFileTokenSource reads the token in the file at path.
+func NewFileTokenSource(path string) (*FileTokenSource, error) {
+	s := &FileTokenSource{path: path}
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Token implements oauth2.TokenSource.
+func (s *FileTokenSource) Token() (*oauth2.Token, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return &oauth2.Token{AccessToken: s.token}, nil
+}
+
+func (s *FileTokenSource) reload() error {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return err
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return fmt.Errorf("%s holds no token", s.path)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.token = token
+	return nil
+}
+
+// Start watches the file until ctx is done. The directory of the file is
+// watched rather than the file itself since Secret volumes are updated by
+// swapping a symlink. When the file can't be read the last token is kept.
+func (s *FileTokenSource) Start(ctx context.Context) error {
+	log := logr.FromContextOrDiscard(ctx)
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+	if err := watcher.Add(filepath.Dir(s.path)); err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case _, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if err := s.reload(); err != nil {
+				log.Error(err, "unable to reload the GitHub token, keeping the previous one", "path", s.path)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			log.Error(err, "error watching the GitHub token file", "path", s.path)
+		}
+	}
+}
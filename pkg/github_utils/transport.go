@@ -0,0 +1,83 @@
+package github_utils
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// TransportOptions configure the HTTP connections to GitHub. Zero values
+// keep the defaults of http.DefaultTransport.
+type TransportOptions struct {
+	// ProxyURL is the proxy requests go through. When empty the proxy is
+	// taken from the HTTPS_PROXY and NO_PROXY environment variables.
+	ProxyURL string
+	// CABundle holds PEM encoded CA certificates that are trusted on top of
+	// the system ones, e.g. the CA of a proxy that re-signs TLS.
+	CABundle []byte
+	// Timeout limits every request, including reading the response body.
+	Timeout             time.Duration
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	MaxConnsPerHost     int
+}
+
+// Merge returns the options with every field that's set in override replaced.
+func (o TransportOptions) Merge(override TransportOptions) TransportOptions {
+	if override.ProxyURL != "" {
+		o.ProxyURL = override.ProxyURL
+	}
+	if len(override.CABundle) > 0 {
+		o.CABundle = override.CABundle
+	}
+	if override.Timeout != 0 {
+		o.Timeout = override.Timeout
+	}
+	if override.MaxIdleConns != 0 {
+		o.MaxIdleConns = override.MaxIdleConns
+	}
+	if override.MaxIdleConnsPerHost != 0 {
+		o.MaxIdleConnsPerHost = override.MaxIdleConnsPerHost
+	}
+	if override.MaxConnsPerHost != 0 {
+		o.MaxConnsPerHost = override.MaxConnsPerHost
+	}
+	return o
+}
+
+// NewTransport builds a transport with the proxy, CA bundle and connection
+// pool limits of the options. The timeout is applied by the client.
+func NewTransport(opts TransportOptions) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if opts.ProxyURL != "" {
+		proxyURL, err := url.Parse(opts.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	if len(opts.CABundle) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(opts.CABundle) {
+			return nil, errors.New("the CA bundle holds no PEM encoded certificate")
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+	if opts.MaxIdleConns != 0 {
+		transport.MaxIdleConns = opts.MaxIdleConns
+	}
+	if opts.MaxIdleConnsPerHost != 0 {
+		transport.MaxIdleConnsPerHost = opts.MaxIdleConnsPerHost
+	}
+	if opts.MaxConnsPerHost != 0 {
+		transport.MaxConnsPerHost = opts.MaxConnsPerHost
+	}
+	return transport, nil
+}